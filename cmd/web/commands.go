@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gummy789j/bookings/internal/driver"
+	"github.com/gummy789j/bookings/internal/repository"
+)
+
+// commands are run instead of the server when named on the command line after the flags. migrate works on
+// the connection itself, the others go through the repository.
+func commands(db *driver.DB, repo repository.DatabaseRepo) map[string]func(args []string) error {
+	return map[string]func(args []string) error{
+		"migrate":           func(args []string) error { return migrateCommand(db, args) },
+		"create-user":       func(args []string) error { return createUserCommand(repo, args) },
+		"reset-password":    func(args []string) error { return resetPasswordCommand(repo, args) },
+		"seed-demo-data":    func(args []string) error { return seedDemoDataCommand(repo, args) },
+		"list-reservations": func(args []string) error { return listReservationsCommand(repo, args) },
+		"block-room":        func(args []string) error { return blockRoomCommand(repo, args) },
+		"send-test-mail":    sendTestMailCommand,
+	}
+}
+
+// runCommand runs the command named by the first argument with the rest as its arguments
+func runCommand(db *driver.DB, repo repository.DatabaseRepo, args []string) error {
+	commands := commands(db, repo)
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return fmt.Errorf("unknown command %q, the commands are: %s", args[0], strings.Join(names, ", "))
+	}
+
+	return cmd(args[1:])
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gummy789j/bookings/internal/handlers"
+)
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		wantErr string
+		wantOut string
+	}{
+		{"unknown command", []string{"frobnicate"}, "", "unknown command \"frobnicate\"", ""},
+		{"create user", []string{"create-user", "-email", "missing@here.com", "-access-level", "3"}, "correct horse\n", "", "created user 2, missing@here.com"},
+		{"create user password without newline", []string{"create-user", "-email", "missing@here.com"}, "correct horse", "", "created user 2"},
+		{"create user short password", []string{"create-user", "-email", "missing@here.com"}, "short\n", "at least 8 characters", ""},
+		{"create user taken email", []string{"create-user", "-email", "taken@here.com"}, "correct horse\n", "already a user with email taken@here.com", ""},
+		{"create user bad email", []string{"create-user", "-email", "nobody"}, "", "--email must be a valid email address", ""},
+		{"create user bad access level", []string{"create-user", "-email", "missing@here.com", "-access-level", "4"}, "", "--access-level must be 1, 2 or 3", ""},
+		{"reset password", []string{"reset-password", "-email", "admin@here.com"}, "correct horse\n", "", "reset the password of user 1"},
+		{"reset password missing user", []string{"reset-password", "-email", "missing@here.com"}, "correct horse\n", "no user with email missing@here.com", ""},
+		{"seed demo data", []string{"seed-demo-data", "-days", "10"}, "", "", "demo reservations"},
+		{"list reservations", []string{"list-reservations", "-from", "2026-11-01", "-to", "2026-11-30"}, "", "", "John Smith"},
+		{"list reservations bad date", []string{"list-reservations", "-from", "01/11/2026"}, "", "--from must be a date", ""},
+		{"list reservations error", []string{"list-reservations", "-room", "1000"}, "", "Some error!", ""},
+		{"block room", []string{"block-room", "-room", "2", "-from", "2026-12-24", "-to", "2026-12-26"}, "", "", "blocked Test Room from 2026-12-24 to 2026-12-26"},
+		{"block room missing room", []string{"block-room", "-room", "404", "-from", "2026-12-24"}, "", "there is no room 404", ""},
+		{"block room unavailable", []string{"block-room", "-room", "1002", "-from", "2026-12-24"}, "", "already booked or blocked", ""},
+		{"block room dates reversed", []string{"block-room", "-room", "2", "-from", "2026-12-24", "-to", "2026-12-20"}, "", "--to can't be before --from", ""},
+		{"send test mail bad address", []string{"send-test-mail", "-to", "nobody"}, "", "--to must be a valid email address", ""},
+	}
+
+	for _, e := range tests {
+		var out bytes.Buffer
+		stdout = &out
+		stdin = strings.NewReader(e.stdin)
+
+		err := runCommand(nil, handlers.Repo.DB, e.args)
+
+		if e.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+		} else if e.wantErr != "" && (err == nil || !strings.Contains(err.Error(), e.wantErr)) {
+			t.Errorf("%s: got error %v, wanted one containing %q", e.name, err, e.wantErr)
+		}
+
+		if !strings.Contains(out.String(), e.wantOut) {
+			t.Errorf("%s: got output %q, wanted it to contain %q", e.name, out.String(), e.wantOut)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"github.com/gummy789j/bookings/internal/handlers"
+	"github.com/gummy789j/bookings/internal/icalsync"
+)
+
+// StartICalImporter imports the external iCal feeds in the background every app.ICalInterval
+func StartICalImporter() {
+	importer := icalsync.NewImporter(handlers.Repo.DB, app.Logger)
+
+	go importer.Start(app.ICalInterval, nil)
+}
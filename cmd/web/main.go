@@ -1,45 +1,84 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/gummy789j/bookings/internal/assets"
 	"github.com/gummy789j/bookings/internal/config"
 	"github.com/gummy789j/bookings/internal/driver"
 	"github.com/gummy789j/bookings/internal/handlers"
 	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/metrics"
 	"github.com/gummy789j/bookings/internal/models"
 	"github.com/gummy789j/bookings/internal/render"
 )
 
-const portNum = ":8081"
-
 var app config.AppConfig
 
 var session *scs.SessionManager
 
-var infoLog *log.Logger
-var errorLog *log.Logger
+// templateReloadInterval is how often template files are checked for changes in development
+const templateReloadInterval = 500 * time.Millisecond
+
+const (
+	// shutdownDrain is how long the server keeps serving after reporting not ready, for load balancers to notice
+	shutdownDrain = 5 * time.Second
+
+	// shutdownTimeout is how long requests being served are given to finish
+	shutdownTimeout = 30 * time.Second
+)
 
 func main() {
 
 	db, err := run()
-	if err != nil {
-		log.Fatal(err)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fatal(err)
 	}
 
 	defer db.SQL.Close()
 
+	if len(app.Settings.Args) > 0 {
+		if err := runCommand(db, handlers.Repo.DB, app.Settings.Args); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// idle connections are dropped while the database is down, so the pool reconnects once it is back
+	if app.Settings.DB.HealthInterval > 0 {
+		go db.Watch(ctx, app.Settings.DB.HealthInterval, app.Settings.DB.PingTimeout, app.Logger)
+	}
+
 	defer close(app.MailChan)
 
 	ListenForMail()
 
+	defer close(app.WebhookChan)
+
+	ListenForWebhooks()
+
+	StartICalImporter()
+
+	StartTrashPurger()
+
 	// from := "me@here.com"
 	// auth := smtp.PlainAuth("", from, "", "localhost")
 	// err = smtp.SendMail("localhost:1025", auth, from, []string{"you@here.com"}, []byte("Hello, world"))
@@ -51,17 +90,57 @@ func main() {
 
 	//http.HandleFunc("/about", handlers.Repo.About)
 
-	fmt.Printf("Starting application on port %s\n", portNum)
+	app.Logger.Info("Starting application", slog.String("addr", app.Settings.Addr()))
 
 	//_ = http.ListenAndServe(portNum, nil)
 
+	if app.Metrics != nil {
+		ServeMetrics()
+	}
+
 	srv := &http.Server{
-		Addr:    portNum,
+		Addr:    app.Settings.Addr(),
 		Handler: routes(&app),
 	}
 
-	err = srv.ListenAndServe()
-	log.Fatal(err)
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	shutdown(srv)
+}
+
+// shutdown stops the server gracefully. /readyz answers not ready first, so that load balancers stop sending
+// requests before the server stops accepting them, and requests already being served are given time to finish.
+func shutdown(srv *http.Server) {
+	app.Logger.Info("Shutting down")
+	app.Health.ShutDown()
+
+	if app.InProduction {
+		time.Sleep(shutdownDrain)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		app.Logger.Error("cannot shut down gracefully", logging.Err(err))
+	}
+}
+
+// fatal logs the error that stops the program and exits with a non-zero status
+func fatal(err error) {
+	if app.Logger == nil {
+		log.Fatal(err)
+	}
+
+	app.Logger.Error(err.Error())
+	os.Exit(1)
 }
 
 func run() (*driver.DB, error) {
@@ -76,46 +155,42 @@ func run() (*driver.DB, error) {
 	gob.Register(models.Restriction{})
 	gob.Register(models.Room{})
 	gob.Register(models.RoomRestriction{})
-	gob.Register(map[string]int{})
-
-	// read flags
-	inProduction := flag.Bool("production", true, "Application is in production")
-	useCache := flag.Bool("cache", true, "Use template cache")
-	dbHost := flag.String("dbhost", "localhost", "Database host")
-	dbName := flag.String("dbname", "", "Database name")
-	dbUser := flag.String("dbuser", "", "Database user")
-	dbPwd := flag.String("dbpwd", "", "Database password")
-	dbPort := flag.String("dbport", "5432", "Database port")
-	dbSSL := flag.String("dbssl", "disable", "Database ssl settings")
-
-	flag.Parse()
-	if *dbName == "" || *dbUser == "" {
-		fmt.Println("Missing required flags")
-		os.Exit(1)
+
+	// read the settings from the config file, environment and flags
+	settings, err := config.LoadFromEnvironment()
+	if err != nil {
+		return nil, err
 	}
 
-	// Build a new mail channal
-	mailChan := make(chan models.MailData)
+	app.Settings = settings
+
+	// Build a new mail channal, buffered so a slow mail server doesn't hold up the page and the queue can be measured
+	mailChan := make(chan models.MailData, 100)
 	app.MailChan = mailChan
 
+	// Build a new webhook channel
+	app.WebhookChan = make(chan models.WebhookEvent, 100)
+
 	//  change this when in production
-	app.InProduction = *inProduction
+	app.InProduction = settings.InProduction
 
-	// Build a new info logger for later
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	app.ICalInterval = settings.ICalInterval
 
-	// store the new info logger
-	app.InfoLog = infoLog
+	app.TrashRetention = settings.TrashRetention
 
-	// Build a new error logger for later
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	// JSON lines in production, readable text while developing
+	level, _ := settings.Level()
+	app.Logger = logging.New(os.Stdout, app.InProduction, level)
 
-	// store the new error logger
-	app.ErrorLog = errorLog
+	// anything still written with the log package goes through the same logger
+	slog.SetDefault(app.Logger)
+
+	// secrets are masked when the settings are logged
+	app.Logger.Info("Settings", slog.Any("settings", settings))
 
 	// Build a new Session manager and set some parameters
 	session = scs.New()
-	session.Lifetime = 24 * time.Hour
+	session.Lifetime = settings.SessionLifetime
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteLaxMode
 	session.Cookie.Secure = app.InProduction
@@ -124,27 +199,71 @@ func run() (*driver.DB, error) {
 	app.Session = session
 
 	// connect with database
-	log.Println("Connecting to database...")
-	connectionString := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s", *dbHost, *dbPort, *dbName, *dbUser, *dbPwd, *dbSSL)
-	db, err := driver.ConnectSQL(connectionString)
+	app.Logger.Info("Connecting to database...", slog.String("host", settings.DB.Host), slog.Int("port", settings.DB.Port), slog.String("name", settings.DB.Name))
+	db, err := driver.ConnectSQL(context.Background(), settings.DB.DSN(), settings.DB.Options(), app.Logger)
 	if err != nil {
-		log.Fatal("Cannot connect to database! Dying...")
+		return nil, fmt.Errorf("cannot connect to database %s on %s:%d as %s: %v", settings.DB.Name, settings.DB.Host, settings.DB.Port, settings.DB.User, err)
 	}
 
-	log.Println("Connected to database!")
+	app.Logger.Info("Connected to database!")
+
+	// only the server is scraped, commands have nothing to report
+	if settings.MetricsPort != 0 && len(settings.Args) == 0 {
+		app.Metrics = metrics.New()
+		app.Metrics.WatchDB(db.SQL)
+		app.Metrics.WatchMailQueue(func() int { return len(app.MailChan) })
+	}
 
-	// CreateTemplateCache to help the development faster (do not need to re-execute when modified templates)
-	tc, err := render.CreateTemplateCache()
+	// commands such as migrate down must see the database as it is
+	if settings.MigrateOnStart && len(settings.Args) == 0 {
+		err = migrateOnStartup(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// templates and static files are built in, unless -assets points at a checkout to edit them live
+	files, err := assets.Load(settings.AssetsDir)
 	if err != nil {
-		log.Fatal("cannot create template cache")
 		return nil, err
 	}
 
-	// store template cache
-	app.TemplateCache = tc
+	app.Assets = files
+	app.Static = assets.NewStaticFiles(files)
+
+	// After your handler end you need to render the template on the browser
+	render.NewRenderer(&app)
 
 	// whether using template cache or not
-	app.UseCache = *useCache
+	app.UseCache = settings.UseCache
+
+	// without the cache the templates are reloaded as they are edited, which only makes sense for files on disk
+	// in development
+	if !app.UseCache {
+		switch {
+		case app.InProduction:
+			app.Logger.Info("The template cache is always used in production")
+			app.UseCache = true
+		case files.Embedded:
+			app.Logger.Info("The built in templates can't change, start with -assets to edit them live")
+			app.UseCache = true
+		}
+	}
+
+	if app.UseCache {
+		// CreateTemplateCache to help the development faster (do not need to re-execute when modified templates)
+		tc, err := render.CreateTemplateCache()
+		if err != nil {
+			return nil, fmt.Errorf("cannot create template cache: %v", err)
+		}
+
+		// store template cache
+		app.TemplateCache = tc
+	} else {
+		// a template that doesn't parse shows on an error page until it is fixed
+		render.WatchTemplates(files.Templates, templateReloadInterval)
+		app.Logger.Info("Reloading templates as they change")
+	}
 
 	// Build a new repository which is when you get the request and call handler, it can store the data and function that you need
 	repo := handlers.NewRepo(&app, db)
@@ -152,11 +271,11 @@ func run() (*driver.DB, error) {
 	// Build a new handlers
 	handlers.NewHandlers(repo)
 
-	// After your handler end you need to render the template on the browser
-	render.NewRenderer(&app)
-
 	// Helper can help you to handle the error message
 	helpers.NewHelpers(&app)
 
+	// answered on /readyz
+	app.Health = newReadiness(db)
+
 	return db, nil
 }
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/middleware"
+	"github.com/gummy789j/bookings/internal/handlers"
 	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
 	"github.com/justinas/nosurf"
 )
 
@@ -42,6 +51,60 @@ func WriteToConsole(next http.Handler) http.Handler {
 	})
 }
 
+// RequestID gives every request an ID, kept from the X-Request-ID header of a proxy when it sends a usable one.
+// The ID is in the request context for the logger and answered in the same header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(logging.RequestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// probes are the paths of health checks, only logged at debug level
+var probes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// AccessLog logs every request once it has been answered, with its status, size and how long it took
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case probes[r.URL.Path]:
+			// load balancers probe every few seconds
+			level = slog.LevelDebug
+		}
+
+		app.Logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
+
 // NoSurf : adds CSRF protection to all POST requests
 func NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
@@ -53,6 +116,13 @@ func NoSurf(next http.Handler) http.Handler {
 		SameSite: http.SameSiteLaxMode,
 	})
 
+	// API calls authenticated by a bearer key are not made by a browser, so they carry no CSRF token;
+	// the key itself is checked by APIKey
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		_, ok := helpers.BearerToken(r)
+		return ok && strings.HasPrefix(r.URL.Path, "/api/")
+	})
+
 	return csrfHandler
 }
 
@@ -72,3 +142,98 @@ func Auth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// statusWriter remembers the status code written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (this *statusWriter) WriteHeader(status int) {
+	this.status = status
+	this.ResponseWriter.WriteHeader(status)
+}
+
+// APIKey authenticates API requests that carry an "Authorization: Bearer" key and audits every call made with one
+func APIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := helpers.BearerToken(r)
+		if !ok {
+			helpers.ErrorJSON(w, http.StatusUnauthorized, "Authorization header must be a Bearer token", nil)
+			return
+		}
+
+		key, err := handlers.Repo.DB.GetAPIKeyByHash(helpers.HashAPIKey(token))
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && key.Revoked()) {
+			helpers.ErrorJSON(w, http.StatusUnauthorized, "Invalid or revoked API key", nil)
+			return
+		} else if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		err = handlers.Repo.DB.TouchAPIKey(key.ID)
+		if err != nil {
+			app.Logger.ErrorContext(r.Context(), "cannot record API key use", logging.Err(err))
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sw, r.WithContext(helpers.WithAPIKey(r.Context(), key)))
+
+		err = handlers.Repo.DB.InsertAPIKeyCall(models.APIKeyCall{
+			APIKeyID: key.ID,
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Status:   sw.status,
+			RemoteIP: r.RemoteAddr,
+		})
+		if err != nil {
+			app.Logger.ErrorContext(r.Context(), "cannot record API call", logging.Err(err))
+		}
+	})
+}
+
+// APIKeyScope requires an API key to have scope, while letting requests without a key through. It guards
+// endpoints that are public, such as booking, so that a read key can't be used to write.
+func APIKeyScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, ok := helpers.APIKeyFromContext(r.Context()); ok && !key.Allows(scope) {
+				helpers.ErrorJSON(w, http.StatusForbidden, fmt.Sprintf("API key does not have %s scope", scope), nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIAuth requires either a logged in user or an API key allowed the given scope ("read" or "write")
+func APIAuth(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, ok := helpers.APIKeyFromContext(r.Context()); ok {
+				if !key.Allows(scope) {
+					helpers.ErrorJSON(w, http.StatusForbidden, fmt.Sprintf("API key does not have %s scope", scope), nil)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !helpers.IsAuthenticated(r) {
+				helpers.ErrorJSON(w, http.StatusUnauthorized, "Authentication required", nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
 )
 
 func TestNoSurf(t *testing.T) {
@@ -18,3 +26,152 @@ func TestNoSurf(t *testing.T) {
 		t.Error("type is not http.Handler\n")
 	}
 }
+
+func TestAPIKeyAndAPIAuth(t *testing.T) {
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := SessionLoad(APIKey(APIAuth("write")(ok)))
+
+	var tests = []struct {
+		name               string
+		authorization      string
+		expectedStatusCode int
+	}{
+		{"no credentials", "", http.StatusUnauthorized},
+		{"write key", "Bearer write-key", http.StatusOK},
+		{"read key", "Bearer read-key", http.StatusForbidden},
+		{"revoked key", "Bearer revoked-key", http.StatusUnauthorized},
+		{"unknown key", "Bearer unknown-key", http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("DELETE", "/api/v1/reservations/1", nil)
+		if e.authorization != "" {
+			req.Header.Set("Authorization", e.authorization)
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+func TestCreateReservationRequiresWriteScope(t *testing.T) {
+
+	body := `{"first_name":"John","last_name":"Smith","email":"john@here.com","start_date":"2050-01-01","end_date":"2050-01-02","room_id":2}`
+
+	var tests = []struct {
+		name               string
+		authorization      string
+		expectedStatusCode int
+	}{
+		{"read key", "Bearer read-key", http.StatusForbidden},
+		{"write key", "Bearer write-key", http.StatusCreated},
+	}
+
+	// a reservation made sends mail and fires a webhook
+	mailChan, webhookChan := app.MailChan, app.WebhookChan
+	app.MailChan = make(chan models.MailData, 10)
+	app.WebhookChan = make(chan models.WebhookEvent, 10)
+	defer func() { app.MailChan, app.WebhookChan = mailChan, webhookChan }()
+
+	mux := routes(&app)
+
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/reservations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", e.authorization)
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s, expected %d but got %d: %s", e.name, e.expectedStatusCode, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestNoSurfExemptsBearerAPICalls(t *testing.T) {
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := NoSurf(ok)
+
+	req := httptest.NewRequest("POST", "/api/v1/reservations", nil)
+	req.Header.Set("Authorization", "Bearer write-key")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected bearer API call to skip CSRF check, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/reservations", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code == http.StatusOK {
+		t.Error("expected API call without a key to require a CSRF token")
+	}
+
+	req = httptest.NewRequest("POST", "/admin/reservations-calendar", nil)
+	req.Header.Set("Authorization", "Bearer write-key")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code == http.StatusOK {
+		t.Error("expected non API call with a bearer token to require a CSRF token")
+	}
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	saved := app.Logger
+	app.Logger = logging.New(&buf, true, slog.LevelInfo)
+	defer func() { app.Logger = saved }()
+
+	var seen string
+	h := RequestID(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})))
+
+	req := httptest.NewRequest("GET", "/teapot", nil)
+	req.Header.Set(logging.RequestIDHeader, "from-proxy")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if seen != "from-proxy" || rr.Header().Get(logging.RequestIDHeader) != "from-proxy" {
+		t.Errorf("expected the proxy's request ID to be kept, got %q in the context and %q in the response", seen, rr.Header().Get(logging.RequestIDHeader))
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON access log record, got %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "from-proxy" || record["path"] != "/teapot" || record["status"] != float64(http.StatusTeapot) || record["bytes"] != float64(15) {
+		t.Errorf("unexpected access log record %v", record)
+	}
+	if _, ok := record["latency"]; !ok {
+		t.Errorf("expected the latency to be logged, got %v", record)
+	}
+
+	req = httptest.NewRequest("GET", "/teapot", nil)
+	req.Header.Set(logging.RequestIDHeader, "not a usable id")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if seen == "not a usable id" || !logging.ValidRequestID(seen) || rr.Header().Get(logging.RequestIDHeader) != seen {
+		t.Errorf("expected a new request ID, got %q", seen)
+	}
+}
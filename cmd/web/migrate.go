@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/gummy789j/bookings/internal/driver"
+	"github.com/gummy789j/bookings/internal/migrate"
+	"github.com/gummy789j/bookings/migrations"
+)
+
+const migrateUsage = "usage: bookings migrate up | down [steps] | status"
+
+// migrateCommand applies, rolls back or lists the embedded database migrations
+func migrateCommand(db *driver.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := migrate.New(db.SQL, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			fmt.Println("applied", mig)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("the database is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			fmt.Println("rolled back", mig)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED\t")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied() {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			note := ""
+			if s.Modified {
+				note = "changed since it was applied"
+			} else if s.Unknown {
+				note = "not in the migration files"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Version, s.Name, applied, note)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
+
+// migrateOnStartup applies any pending migration before the server starts
+func migrateOnStartup(db *driver.DB) error {
+	m, err := migrate.New(db.SQL, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	done, err := m.Up(context.Background())
+	for _, mig := range done {
+		app.Logger.Info("Applied migration", slog.String("migration", mig.String()))
+	}
+
+	return err
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+// stdout and stdin are where the commands write their output and read passwords from
+var (
+	stdout io.Writer = os.Stdout
+	stdin  io.Reader = os.Stdin
+)
+
+const cliDateLayout = "2006-01-02"
+
+// minPasswordLength is the shortest password the commands accept
+const minPasswordLength = 8
+
+// commandFlags returns a flag set for a command that reports problems as errors instead of exiting
+func commandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	return fs
+}
+
+// parseDate reads a YYYY-MM-DD flag value
+func parseDate(flagName, value string) (time.Time, error) {
+	date, err := time.Parse(cliDateLayout, value)
+	if err != nil {
+		return date, fmt.Errorf("--%s must be a date like 2026-11-02", flagName)
+	}
+	return date, nil
+}
+
+// readPassword asks for a password. On a terminal it is read without echoing, otherwise it is the first line
+// of stdin, so that scripts can pipe it in without it showing up in ps or the shell history.
+func readPassword() (string, error) {
+	var password string
+
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(stdout, "Password: ")
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(stdout)
+		if err != nil {
+			return "", err
+		}
+		password = string(b)
+	} else {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("the password must be at least %d characters", minPasswordLength)
+	}
+
+	return password, nil
+}
+
+// recordSystemAudit records a change made by the application itself or from the command line, which has
+// no logged in user or remote address
+func recordSystemAudit(db repository.DatabaseRepo, action, entity string, id int, before, after audit.Snapshot) {
+	err := db.InsertAuditEntry(models.AuditEntry{
+		Action:   action,
+		Entity:   entity,
+		EntityID: id,
+		Changes:  audit.Changes(before, after),
+	})
+	if err != nil {
+		app.Logger.Error("cannot record audit entry", slog.String("action", action), logging.Err(err))
+	}
+}
+
+// createUserCommand adds a user who can log in to the admin pages
+func createUserCommand(db repository.DatabaseRepo, args []string) error {
+	fs := commandFlags("create-user")
+	email := fs.String("email", "", "Email address to log in with (required)")
+	firstName := fs.String("first-name", "", "First name")
+	lastName := fs.String("last-name", "", "Last name")
+	accessLevel := fs.Int("access-level", 1, "Access level, 1 to 3 where 3 is an administrator")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !govalidator.IsEmail(*email) {
+		return errors.New("--email must be a valid email address")
+	}
+
+	if *accessLevel < 1 || *accessLevel > 3 {
+		return errors.New("--access-level must be 1, 2 or 3")
+	}
+
+	_, err := db.GetUserByEmail(*email)
+	if err == nil {
+		return fmt.Errorf("there is already a user with email %s", *email)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	pwd, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), 12)
+	if err != nil {
+		return err
+	}
+
+	user := models.User{
+		FirstName:   *firstName,
+		LastName:    *lastName,
+		Email:       *email,
+		Password:    string(hash),
+		AccessLevel: *accessLevel,
+	}
+
+	user.ID, err = db.InsertUser(user)
+	if err != nil {
+		return err
+	}
+
+	recordSystemAudit(db, audit.ActionCreate, audit.EntityUser, user.ID, nil, audit.User(user))
+
+	fmt.Fprintf(stdout, "created user %d, %s\n", user.ID, user.Email)
+
+	return nil
+}
+
+// resetPasswordCommand sets a new password for a user
+func resetPasswordCommand(db repository.DatabaseRepo, args []string) error {
+	fs := commandFlags("reset-password")
+	email := fs.String("email", "", "Email address of the user (required)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" {
+		return errors.New("--email is required")
+	}
+
+	user, err := db.GetUserByEmail(*email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("there is no user with email %s", *email)
+	} else if err != nil {
+		return err
+	}
+
+	pwd, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), 12)
+	if err != nil {
+		return err
+	}
+
+	err = db.UpdateUserPassword(user.ID, string(hash))
+	if err != nil {
+		return err
+	}
+
+	// the hash is not recorded, so the entry notes that the password changed instead
+	after := audit.User(user)
+	after["password_changed"] = true
+
+	recordSystemAudit(db, audit.ActionResetPassword, audit.EntityUser, user.ID, audit.User(user), after)
+
+	fmt.Fprintf(stdout, "reset the password of user %d, %s\n", user.ID, user.Email)
+
+	return nil
+}
+
+var demoGuests = []struct{ first, last string }{
+	{"John", "Smith"}, {"Jane", "Doe"}, {"Maria", "Garcia"}, {"Wei", "Chen"}, {"Aiko", "Tanaka"},
+	{"Lars", "Nielsen"}, {"Amara", "Okafor"}, {"Pierre", "Martin"}, {"Sofia", "Rossi"}, {"Liam", "Murphy"},
+}
+
+// seedDemoDataCommand fills the coming days with made up reservations and a few owner blocks, leaving
+// nights that are already taken alone
+func seedDemoDataCommand(db repository.DatabaseRepo, args []string) error {
+	fs := commandFlags("seed-demo-data")
+	days := fs.Int("days", 60, "How many days from today to fill")
+	seed := fs.Int64("seed", 1, "Random seed, the same seed makes the same data")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *days < 1 {
+		return errors.New("--days must be positive")
+	}
+
+	rooms, err := db.AllRooms()
+	if err != nil {
+		return err
+	}
+
+	if len(rooms) == 0 {
+		return errors.New("there are no rooms, run migrate up first")
+	}
+
+	rnd := rand.New(rand.NewSource(*seed))
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	last := today.AddDate(0, 0, *days)
+
+	var reservations []models.Reservation
+	var blocks []models.RoomRestriction
+
+	for _, room := range rooms {
+		for start := today.AddDate(0, 0, rnd.Intn(3)); start.Before(last); {
+			end := start.AddDate(0, 0, 1+rnd.Intn(5))
+
+			free, err := db.SearchAvailabilityByDatesByRoomID(start, end, room.ID)
+			if err != nil {
+				return err
+			}
+
+			if free {
+				if rnd.Intn(8) == 0 {
+					blocks = append(blocks, models.RoomRestriction{RoomID: room.ID, StartDate: start, EndDate: start.AddDate(0, 0, 1), RestrictionID: 2})
+					end = start.AddDate(0, 0, 1)
+				} else {
+					guest := demoGuests[rnd.Intn(len(demoGuests))]
+					reservations = append(reservations, models.Reservation{
+						FirstName: guest.first,
+						LastName:  guest.last,
+						Email:     strings.ToLower(guest.first+"."+guest.last) + "@example.com",
+						Phone:     fmt.Sprintf("555-%04d", rnd.Intn(10000)),
+						StartDate: start,
+						EndDate:   end,
+						RoomID:    room.ID,
+					})
+				}
+			}
+
+			start = end.AddDate(0, 0, rnd.Intn(4))
+		}
+	}
+
+	err = db.ImportReservations(reservations, blocks)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range reservations {
+		recordSystemAudit(db, audit.ActionCreate, audit.EntityReservation, res.ID, nil, audit.Reservation(res))
+	}
+	for _, block := range blocks {
+		recordSystemAudit(db, audit.ActionCreate, audit.EntityBlock, block.ID, nil, audit.Block(block))
+	}
+
+	fmt.Fprintf(stdout, "added %d demo reservations and %d blocked nights\n", len(reservations), len(blocks))
+
+	return nil
+}
+
+// listReservationsCommand prints the reservations arriving between two dates
+func listReservationsCommand(db repository.DatabaseRepo, args []string) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	fs := commandFlags("list-reservations")
+	from := fs.String("from", today.Format(cliDateLayout), "First arrival date")
+	to := fs.String("to", today.AddDate(0, 0, 30).Format(cliDateLayout), "Last arrival date")
+	roomID := fs.Int("room", 0, "Only this room")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var f models.ReservationFilter
+	var err error
+
+	if f.From, err = parseDate("from", *from); err != nil {
+		return err
+	}
+
+	if f.To, err = parseDate("to", *to); err != nil {
+		return err
+	}
+
+	if f.To.Before(f.From) {
+		return errors.New("--to can't be before --from")
+	}
+
+	f.RoomID = *roomID
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tARRIVAL\tDEPARTURE\tROOM\tGUEST\tEMAIL\tSTATUS")
+
+	count := 0
+	err = db.EachReservation(f, func(res models.Reservation) error {
+		status := models.StatusNew
+		if res.Processed == 1 {
+			status = models.StatusProcessed
+		}
+
+		count++
+		_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s %s\t%s\t%s\n", res.ID, res.StartDate.Format(cliDateLayout), res.EndDate.Format(cliDateLayout),
+			res.Room.RoomName, res.FirstName, res.LastName, res.Email, status)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%d reservations\n", count)
+
+	return nil
+}
+
+// blockRoomCommand closes a room for owner use over a range of nights
+func blockRoomCommand(db repository.DatabaseRepo, args []string) error {
+	fs := commandFlags("block-room")
+	roomID := fs.Int("room", 0, "Room to block (required)")
+	from := fs.String("from", "", "First night to block (required)")
+	to := fs.String("to", "", "Last night to block, the same as --from when not given")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *roomID == 0 {
+		return errors.New("--room is required")
+	}
+
+	start, err := parseDate("from", *from)
+	if err != nil {
+		return err
+	}
+
+	lastNight := start
+	if *to != "" {
+		if lastNight, err = parseDate("to", *to); err != nil {
+			return err
+		}
+	}
+
+	if lastNight.Before(start) {
+		return errors.New("--to can't be before --from")
+	}
+
+	room, err := db.GetRoomByID(*roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("there is no room %d", *roomID)
+	} else if err != nil {
+		return err
+	}
+
+	block := models.RoomRestriction{RoomID: room.ID, StartDate: start, EndDate: lastNight.AddDate(0, 0, 1), RestrictionID: 2}
+
+	free, err := db.SearchAvailabilityByDatesByRoomID(block.StartDate, block.EndDate, room.ID)
+	if err != nil {
+		return err
+	}
+
+	if !free {
+		return fmt.Errorf("%s is already booked or blocked on some of those nights", room.RoomName)
+	}
+
+	blocks := []models.RoomRestriction{block}
+
+	err = db.ImportReservations(nil, blocks)
+	if err != nil {
+		return err
+	}
+
+	recordSystemAudit(db, audit.ActionCreate, audit.EntityBlock, blocks[0].ID, nil, audit.Block(blocks[0]))
+
+	fmt.Fprintf(stdout, "blocked %s from %s to %s\n", room.RoomName, start.Format(cliDateLayout), lastNight.Format(cliDateLayout))
+
+	return nil
+}
+
+// sendTestMailCommand sends a message through the configured mail server and reports whether it went
+func sendTestMailCommand(args []string) error {
+	fs := commandFlags("send-test-mail")
+	to := fs.String("to", "", "Address to send to (required)")
+	from := fs.String("from", "linshotel@hotel.com", "Address to send from")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !govalidator.IsEmail(*to) {
+		return errors.New("--to must be a valid email address")
+	}
+
+	err := sendMsg(models.MailData{
+		To:       *to,
+		From:     *from,
+		Subject:  "Test message",
+		Content:  fmt.Sprintf("<p>This is a test message sent through %s:%d.</p>", app.Settings.SMTP.Host, app.Settings.SMTP.Port),
+		Template: "basic.html",
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "sent a test message to %s\n", *to)
+
+	return nil
+}
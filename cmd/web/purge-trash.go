@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/handlers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/repository"
+)
+
+// StartTrashPurger permanently deletes, once a day, the reservations that have been in the trash longer
+// than app.TrashRetention. A retention of zero keeps them forever.
+func StartTrashPurger() {
+	if app.TrashRetention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			purgeTrash(handlers.Repo.DB, time.Now().Add(-app.TrashRetention))
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeTrash deletes the reservations put in the trash before a time, recording each in the audit log
+func purgeTrash(db repository.DatabaseRepo, before time.Time) {
+	ids, err := db.PurgeDeletedReservations(before)
+	if err != nil {
+		app.Logger.Error("cannot purge the trash", logging.Err(err))
+		return
+	}
+
+	for _, id := range ids {
+		recordSystemAudit(db, audit.ActionPurge, audit.EntityReservation, id, nil, nil)
+	}
+
+	if len(ids) > 0 {
+		app.Logger.Info("Purged reservations from the trash", slog.Int("count", len(ids)))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/driver"
+	"github.com/gummy789j/bookings/internal/health"
+	"github.com/gummy789j/bookings/internal/render"
+)
+
+// readinessTimeout is how long the readiness checks may take together, shorter than a load balancer waits
+const readinessTimeout = 2 * time.Second
+
+// newReadiness checks what a request may need: the database pool, the mail server and the templates
+func newReadiness(db *driver.DB) *health.Checker {
+	c := health.New(readinessTimeout, app.Logger)
+
+	c.Add("database", func(ctx context.Context) error {
+		return db.SQL.PingContext(ctx)
+	})
+	c.Add("mail", mailReachable)
+	c.Add("templates", func(ctx context.Context) error {
+		return render.TemplatesReady()
+	})
+
+	return c
+}
+
+// mailReachable connects to the mail server without sending anything
+func mailReachable(ctx context.Context) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(app.Settings.SMTP.Host, strconv.Itoa(app.Settings.SMTP.Port)))
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
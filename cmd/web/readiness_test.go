@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/driver"
+	"github.com/gummy789j/bookings/internal/health"
+)
+
+func TestProbeRoutes(t *testing.T) {
+
+	var app config.AppConfig
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rr := httptest.NewRecorder()
+		routes(&app).ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: expected 200 with JSON, got %d %s", path, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestReadiness(t *testing.T) {
+
+	// a mail server that accepts connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// nothing listens on port 1
+	pool, err := sql.Open("pgx", "host=127.0.0.1 port=1 dbname=bookings user=postgres connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	saved := app.Settings
+	defer func() { app.Settings = saved }()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	app.Settings.SMTP.Host = addr.IP.String()
+	app.Settings.SMTP.Port = addr.Port
+
+	if err := mailReachable(context.Background()); err != nil {
+		t.Errorf("expected the mail server to be reachable, got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	newReadiness(&driver.DB{SQL: pool}).Ready(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 but got %d", rr.Code)
+	}
+
+	var report health.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	// the renderer isn't set up in these tests, so there are no templates either
+	want := map[string]string{"database": "failing", "mail": "ok", "templates": "failing"}
+	if len(report.Checks) != len(want) {
+		t.Fatalf("expected %d checks, got %+v", len(want), report.Checks)
+	}
+	for _, check := range report.Checks {
+		if check.Status != want[check.Name] {
+			t.Errorf("expected %s to be %s, got %s", check.Name, want[check.Name], check.Status)
+		}
+	}
+
+	// and a mail server that is down
+	ln.Close()
+	app.Settings.SMTP.Port = 1
+	if err := mailReachable(context.Background()); err == nil {
+		t.Error("expected the mail server to be unreachable")
+	}
+}
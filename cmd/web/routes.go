@@ -7,12 +7,16 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/gummy789j/bookings/internal/config"
 	"github.com/gummy789j/bookings/internal/handlers"
+	"github.com/gummy789j/bookings/internal/health"
 )
 
 func routes(app *config.AppConfig) http.Handler {
 
 	mux := chi.NewRouter()
 
+	mux.Use(RequestID)
+	mux.Use(AccessLog)
+	mux.Use(app.Metrics.Middleware)
 	mux.Use(middleware.Recoverer)
 
 	//mux.Use(WriteToConsole)
@@ -20,6 +24,13 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Use(SessionLoad)
 	//mux.Use(Auth)
 
+	mux.NotFound(handlers.Repo.NotFound)
+	mux.MethodNotAllowed(handlers.Repo.MethodNotAllowed)
+
+	// probes of the load balancer
+	mux.Get("/healthz", health.Live)
+	mux.Get("/readyz", app.Health.Ready)
+
 	mux.Get("/", handlers.Repo.Home)
 	mux.Get("/about", handlers.Repo.About)
 	mux.Get("/search-availability", handlers.Repo.Availability)
@@ -40,25 +51,95 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Get("/logout", handlers.Repo.Logout)
 	mux.Post("/login", handlers.Repo.PostShowLogin)
 
-	// 只要有Open method的都屬於FileSystem interface
-	// type Dir string 就有Open method
-	// Dir() 更像是一種強制轉型
-	fileServer := http.FileServer(http.Dir("./static/"))
-	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+	mux.Get("/rooms/{id}/calendar/{secret}.ics", handlers.Repo.RoomICalFeed)
+
+	// embedded unless -assets points at a checkout, linked to with {{static "css/styles.css"}} in templates
+	mux.Handle("/static/*", http.StripPrefix("/static", app.Static))
+
+	mux.Get("/api/openapi.json", handlers.Repo.APIOpenAPI)
+
+	mux.Route("/api/v1", func(mux chi.Router) {
+		mux.Use(APIKey)
+
+		mux.NotFound(handlers.Repo.APINotFound)
+		mux.MethodNotAllowed(handlers.Repo.APIMethodNotAllowed)
+
+		mux.Get("/rooms", handlers.Repo.APIListRooms)
+		mux.Get("/availability", handlers.Repo.APIAvailability)
+		mux.With(APIKeyScope("write")).Post("/reservations", handlers.Repo.APICreateReservation)
+
+		mux.With(APIAuth("read")).Get("/reservations/{id}", handlers.Repo.APIGetReservation)
+		mux.With(APIAuth("write")).Delete("/reservations/{id}", handlers.Repo.APICancelReservation)
+	})
 
 	mux.Route("/admin", func(mux chi.Router) {
 		//mux.Use(Auth)
 		mux.Get("/dashboard", handlers.Repo.AdminDashBoard)
+		mux.Get("/dashboard/summary.json", handlers.Repo.AdminDashboardSummary)
+		mux.Get("/dashboard/occupancy.json", handlers.Repo.AdminDashboardOccupancy)
+		mux.Get("/today", handlers.Repo.AdminToday)
+		mux.Get("/today/print", handlers.Repo.AdminTodayPrint)
+		mux.Post("/today/{id}/check-in", handlers.Repo.AdminCheckIn)
+		mux.Post("/today/{id}/check-out", handlers.Repo.AdminCheckOut)
 		mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
 		mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+		mux.Get("/reservations/export", handlers.Repo.AdminExportReservations)
 		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
-		mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
+		mux.Get("/reservations-calendar/data", handlers.Repo.AdminCalendarData)
+		mux.Post("/reservations-calendar/blocks", handlers.Repo.AdminCalendarBlock)
+		mux.Post("/reservations-calendar/blocks/{id}/delete", handlers.Repo.AdminCalendarUnblock)
+		mux.Post("/reservations-calendar/reservations/{id}/move", handlers.Repo.AdminCalendarMoveReservation)
 		mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
 		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
-		mux.Get("/process-reservations/{src}/{id}/do", handlers.Repo.AdminProcessReservation)
-		mux.Get("/delete-reservations/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
+		mux.Post("/process-reservations/{src}/{id}/do", handlers.Repo.AdminProcessReservation)
+		mux.Post("/delete-reservations/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
+		mux.Get("/trash", handlers.Repo.AdminTrash)
+		mux.Post("/trash/{id}/restore", handlers.Repo.AdminRestoreReservation)
+
+		mux.Get("/audit", handlers.Repo.AdminAuditLog)
+
+		// these hand out credentials and secrets or let others write to the bookings, so they need a login
+		// even while the rest of the admin is open
+		mux.Group(func(mux chi.Router) {
+			mux.Use(Auth)
+
+			mux.Get("/api-keys", handlers.Repo.AdminAPIKeys)
+			mux.Post("/api-keys", handlers.Repo.AdminPostAPIKey)
+			mux.Get("/api-keys/{id}/calls", handlers.Repo.AdminAPIKeyCalls)
+			mux.Post("/api-keys/{id}/revoke", handlers.Repo.AdminRevokeAPIKey)
 
+			mux.Get("/rooms", handlers.Repo.AdminRooms)
+			mux.Post("/rooms/{id}/rotate-ical-secret", handlers.Repo.AdminRotateRoomICalSecret)
+
+			mux.Get("/ical-feeds", handlers.Repo.AdminICalFeeds)
+			mux.Post("/ical-feeds", handlers.Repo.AdminPostICalFeed)
+			mux.Get("/ical-feeds/{id}", handlers.Repo.AdminShowICalFeed)
+			mux.Post("/ical-feeds/{id}/import", handlers.Repo.AdminImportICalFeed)
+			mux.Post("/ical-feeds/{id}/delete", handlers.Repo.AdminDeleteICalFeed)
+
+			mux.Get("/import", handlers.Repo.AdminImport)
+			mux.Post("/import", handlers.Repo.AdminPostImport)
+			mux.Post("/import/commit", handlers.Repo.AdminCommitImport)
+
+			mux.Get("/webhooks", handlers.Repo.AdminWebhooks)
+			mux.Post("/webhooks", handlers.Repo.AdminPostWebhook)
+			mux.Get("/webhooks/{id}", handlers.Repo.AdminShowWebhook)
+			mux.Post("/webhooks/{id}/delete", handlers.Repo.AdminDeleteWebhook)
+			mux.Post("/webhooks/deliveries/{id}/redeliver", handlers.Repo.AdminRedeliverWebhook)
+		})
 	})
 
 	return mux
 }
+
+// metricsRoutes serves the metrics for Prometheus, on a port of its own so they aren't public
+func metricsRoutes(app *config.AppConfig) http.Handler {
+
+	mux := chi.NewRouter()
+
+	mux.Use(middleware.Recoverer)
+
+	mux.Method("GET", "/metrics", app.Metrics.Handler())
+
+	return mux
+}
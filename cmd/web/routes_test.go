@@ -1,10 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
 	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/handlers"
+	"github.com/gummy789j/bookings/internal/metrics"
+	"github.com/gummy789j/bookings/internal/openapi"
 )
 
 func TestRoutes(t *testing.T) {
@@ -20,3 +27,166 @@ func TestRoutes(t *testing.T) {
 		t.Error("Type is not *chi.Mux\n")
 	}
 }
+
+func TestRoutesDescribedByOpenAPI(t *testing.T) {
+
+	var app config.AppConfig
+
+	mux := routes(&app).(chi.Routes)
+
+	doc := handlers.OpenAPIDocument()
+
+	found := 0
+
+	err := chi.Walk(mux, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/api/") {
+			return nil
+		}
+
+		found++
+
+		if !doc.Has(method, route) {
+			t.Errorf("%s %s is registered in routes() but missing from the OpenAPI document", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found == 0 {
+		t.Error("no /api routes found")
+	}
+
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/api/") {
+			continue
+		}
+		if item.Get == nil && item.Post == nil && item.Put == nil && item.Patch == nil && item.Delete == nil {
+			t.Errorf("%s has no operations", path)
+		}
+	}
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+
+	var app config.AppConfig
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	rr := httptest.NewRecorder()
+
+	routes(&app).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal("failed to parse json")
+	}
+
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("unexpected openapi version %v", doc["openapi"])
+	}
+}
+
+func TestAdminReservationActionsRequireCSRF(t *testing.T) {
+
+	mux := routes(&app)
+
+	for _, path := range []string{"/admin/process-reservations/new/1/do", "/admin/delete-reservations/new/1/do"} {
+		req := httptest.NewRequest("POST", path, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("POST %s without a CSRF token: got status %d, wanted %d", path, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAdminCredentialRoutesRequireLogin(t *testing.T) {
+
+	mux := routes(&app)
+
+	for _, path := range []string{"/admin/api-keys", "/admin/rooms", "/admin/ical-feeds", "/admin/import", "/admin/webhooks"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+			t.Errorf("GET %s without logging in: got status %d to %q, wanted a redirect to the login", path, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestMetricsRoutes(t *testing.T) {
+
+	saved := app.Metrics
+	app.Metrics = metrics.New()
+	defer func() { app.Metrics = saved }()
+
+	routes(&app).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/openapi.json", nil))
+
+	mux := metricsRoutes(&app)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", rr.Code)
+	}
+
+	line := `bookings_http_requests_total{method="GET",route="/api/openapi.json",status="200"} 1`
+	if !strings.Contains(rr.Body.String(), line) {
+		t.Errorf("expected %q in the metrics", line)
+	}
+
+	// the site itself doesn't expose them
+	rr = httptest.NewRecorder()
+	routes(&app).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected /metrics to be missing from the site but got %d", rr.Code)
+	}
+}
+
+func TestOpenAPISecurityRequirements(t *testing.T) {
+
+	doc := handlers.OpenAPIDocument()
+
+	for path, item := range doc.Paths {
+		for method, op := range map[string]*openapi.Operation{"GET": item.Get, "POST": item.Post, "PUT": item.Put, "PATCH": item.Patch, "DELETE": item.Delete} {
+			if op == nil {
+				continue
+			}
+
+			for _, requirement := range op.Security {
+				for scheme, scopes := range requirement {
+					if _, ok := doc.Components.SecuritySchemes[scheme]; !ok {
+						t.Errorf("%s %s: unknown security scheme %s", method, path, scheme)
+					}
+					// scopes are only allowed for oauth2 and openIdConnect, and the list must not be null
+					if scopes == nil || len(scopes) != 0 {
+						t.Errorf("%s %s: %s must have an empty scope list, got %#v", method, path, scheme, scopes)
+					}
+				}
+			}
+
+			if len(op.Security) > 0 {
+				for _, status := range []string{"401", "403"} {
+					if _, ok := op.Responses[status]; !ok {
+						t.Errorf("%s %s: secured but documents no %s response", method, path, status)
+					}
+				}
+			}
+		}
+	}
+
+	create := doc.Paths["/api/v1/reservations"].Post
+	if len(create.Security) == 0 {
+		t.Error("POST /api/v1/reservations documents no security requirement")
+	}
+}
@@ -2,35 +2,43 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io/fs"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/gummy789j/bookings/internal/logging"
 	"github.com/gummy789j/bookings/internal/models"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
 func ListenForMail() {
 	go func() {
-		for {
-			msg := <-app.MailChan
-			sendMsg(msg)
+		for msg := range app.MailChan {
+			if err := sendMsg(msg); err != nil {
+				app.Metrics.MailFailed()
+				app.Logger.Error("cannot send email", slog.String("to", msg.To), slog.String("subject", msg.Subject), logging.Err(err))
+			} else {
+				app.Logger.Info("Email sent!", slog.String("to", msg.To), slog.String("subject", msg.Subject))
+			}
 		}
 	}()
 }
 
-func sendMsg(m models.MailData) {
+// sendMsg sends a message through the configured mail server
+func sendMsg(m models.MailData) error {
 	server := mail.NewSMTPClient()
-	server.Host = "localhost"
-	server.Port = 1025
+	server.Host = app.Settings.SMTP.Host
+	server.Port = app.Settings.SMTP.Port
+	server.Username = app.Settings.SMTP.Username
+	server.Password = string(app.Settings.SMTP.Password)
 	server.KeepAlive = false
 	server.ConnectTimeout = 10 * time.Second
 	server.SendTimeout = 10 * time.Second
 
 	client, err := server.Connect()
 	if err != nil {
-		errorLog.Println(err)
+		return fmt.Errorf("cannot connect to the mail server: %v", err)
 	}
 
 	email := mail.NewMSG()
@@ -38,9 +46,9 @@ func sendMsg(m models.MailData) {
 	if m.Template == "" {
 		email.SetBody(mail.TextHTML, m.Content)
 	} else {
-		data, err := ioutil.ReadFile(fmt.Sprintf("./email-templates/%s", m.Template))
+		data, err := fs.ReadFile(app.Assets.Email, m.Template)
 		if err != nil {
-			app.ErrorLog.Println(err)
+			return err
 		}
 
 		mailTemplate := string(data)
@@ -50,10 +58,5 @@ func sendMsg(m models.MailData) {
 		email.SetBody(mail.TextHTML, msgToSend)
 	}
 
-	err = email.Send(client)
-	if err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Email sent!")
-	}
+	return email.Send(client)
 }
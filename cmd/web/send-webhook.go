@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/gummy789j/bookings/internal/handlers"
+	"github.com/gummy789j/bookings/internal/webhooks"
+)
+
+// ListenForWebhooks delivers queued webhook events in the background
+func ListenForWebhooks() {
+	dispatcher := webhooks.NewDispatcher(handlers.Repo.DB, app.Logger)
+
+	go func() {
+		for ev := range app.WebhookChan {
+			go dispatcher.Dispatch(ev)
+		}
+	}()
+}
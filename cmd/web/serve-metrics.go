@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gummy789j/bookings/internal/logging"
+)
+
+// ServeMetrics serves /metrics on the metrics port in the background. The port is for Prometheus only and
+// shouldn't be reachable from outside, so failing to listen on it is logged rather than stopping the site.
+func ServeMetrics() {
+	srv := &http.Server{
+		Addr:    app.Settings.MetricsAddr(),
+		Handler: metricsRoutes(&app),
+	}
+
+	go func() {
+		app.Logger.Info("Serving metrics", slog.String("addr", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil {
+			app.Logger.Error("cannot serve metrics", logging.Err(err))
+		}
+	}()
+}
@@ -4,10 +4,32 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gummy789j/bookings/internal/assets"
+	"github.com/gummy789j/bookings/internal/handlers"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
 )
 
 func TestMain(m *testing.M) {
 
+	session = scs.New()
+	session.Lifetime = 24 * time.Hour
+	session.Cookie.Persist = true
+	session.Cookie.SameSite = http.SameSiteLaxMode
+
+	app.Session = session
+
+	app.Logger = logging.Discard()
+
+	app.Assets = assets.Embedded()
+	app.Static = assets.NewStaticFiles(app.Assets)
+
+	helpers.NewHelpers(&app)
+	handlers.NewHandlers(handlers.NewTestRepo(&app))
+
 	// Before exit, run the whole testing in this package
 	os.Exit(m.Run())
 }
@@ -0,0 +1,13 @@
+// Package emailtemplates holds the HTML layouts notification emails are sent in, embedded in the binary.
+package emailtemplates
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.html
+var files embed.FS
+
+// FS is the directory of email templates, whose [%body%] marks where the message goes
+var FS fs.FS = files
@@ -0,0 +1,201 @@
+// Package assets finds the templates and static files the application runs with and serves the static files
+// under content hashed URLs
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	emailtemplates "github.com/gummy789j/bookings/email-templates"
+	"github.com/gummy789j/bookings/static"
+	"github.com/gummy789j/bookings/templates"
+)
+
+// Files are the directories of templates, static files and email templates
+type Files struct {
+	Templates fs.FS
+	Static    fs.FS
+	Email     fs.FS
+
+	// Embedded is false when the files are read from disk and may change while running
+	Embedded bool
+}
+
+// Embedded returns the files built into the binary
+func Embedded() Files {
+	return Files{
+		Templates: templates.FS,
+		Static:    static.FS,
+		Email:     emailtemplates.FS,
+		Embedded:  true,
+	}
+}
+
+// Dir returns the files of a checkout of the repository at root, so that edits show without rebuilding
+func Dir(root string) (Files, error) {
+	dirs := []string{"templates", "static", "email-templates"}
+
+	for _, d := range dirs {
+		info, err := os.Stat(filepath.Join(root, d))
+		if err != nil {
+			return Files{}, fmt.Errorf("cannot read assets from %s: %v", root, err)
+		}
+		if !info.IsDir() {
+			return Files{}, fmt.Errorf("cannot read assets from %s: %s is not a directory", root, d)
+		}
+	}
+
+	return Files{
+		Templates: os.DirFS(filepath.Join(root, dirs[0])),
+		Static:    os.DirFS(filepath.Join(root, dirs[1])),
+		Email:     os.DirFS(filepath.Join(root, dirs[2])),
+	}, nil
+}
+
+// Load returns the files in dir, or the embedded ones when dir is empty
+func Load(dir string) (Files, error) {
+	if dir == "" {
+		return Embedded(), nil
+	}
+	return Dir(dir)
+}
+
+// Static serves static files. Path gives a file's URL with a hash of its content, which is cached by browsers
+// for a year since a changed file gets a new URL. Any other request must be revalidated with the ETag.
+type Static struct {
+	fsys   fs.FS
+	prefix string
+
+	// cache remembers the hashes, which is only right when the files can't change
+	cache  bool
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// immutable is how long a hashed URL may be cached
+const immutable = "public, max-age=31536000, immutable"
+
+// NewStatic serves the files in fsys, which are linked to under prefix
+func NewStatic(fsys fs.FS, prefix string, cache bool) *Static {
+	return &Static{
+		fsys:   fsys,
+		prefix: strings.TrimSuffix(prefix, "/") + "/",
+		cache:  cache,
+		hashes: make(map[string]string),
+	}
+}
+
+// NewStaticFiles serves the static files of f under /static
+func NewStaticFiles(f Files) *Static {
+	return NewStatic(f.Static, "/static", f.Embedded)
+}
+
+// Hash returns a short hash of a file's content
+func (s *Static) Hash(name string) (string, error) {
+	if s.cache {
+		s.mu.Lock()
+		h, ok := s.hashes[name]
+		s.mu.Unlock()
+		if ok {
+			return h, nil
+		}
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+
+	h := hex.EncodeToString(sum.Sum(nil))[:12]
+
+	if s.cache {
+		s.mu.Lock()
+		s.hashes[name] = h
+		s.mu.Unlock()
+	}
+
+	return h, nil
+}
+
+// Path returns the URL of a static file, such as css/styles.css, versioned by its content. A file that
+// can't be read gets its plain URL, so a missing file shows as a 404 in the browser.
+func (s *Static) Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+
+	h, err := s.Hash(name)
+	if err != nil {
+		return s.prefix + name
+	}
+
+	return s.prefix + name + "?v=" + h
+}
+
+// ServeHTTP serves the file named by the request path, which must already have the prefix stripped.
+// Directories aren't listed.
+func (s *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+	if name == "" || strings.HasSuffix(name, ".go") {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h, err := s.Hash(name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+h+`"`)
+
+	if r.URL.Query().Get("v") == h {
+		w.Header().Set("Cache-Control", immutable)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	// embedded files have no modification time, the ETag answers conditional requests instead
+	var modTime time.Time
+	if !s.cache {
+		modTime = info.ModTime()
+	}
+
+	http.ServeContent(w, r, name, modTime, content)
+}
@@ -0,0 +1,86 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStatic(t *testing.T) {
+	s := NewStatic(fstest.MapFS{
+		"css/styles.css": {Data: []byte("body { color: red; }")},
+		"static.go":      {Data: []byte("package static")},
+	}, "/static", true)
+
+	url := s.Path("css/styles.css")
+	if !strings.HasPrefix(url, "/static/css/styles.css?v=") {
+		t.Fatalf("unexpected path %s", url)
+	}
+
+	h, _ := s.Hash("css/styles.css")
+
+	tests := []struct {
+		name         string
+		url          string
+		header       string
+		wantStatus   int
+		cacheControl string
+	}{
+		{"hashed", "/css/styles.css?v=" + h, "", http.StatusOK, immutable},
+		{"stale hash", "/css/styles.css?v=0123456789ab", "", http.StatusOK, "no-cache"},
+		{"plain", "/css/styles.css", "", http.StatusOK, "no-cache"},
+		{"revalidated", "/css/styles.css", `"` + h + `"`, http.StatusNotModified, "no-cache"},
+		{"missing", "/css/missing.css", "", http.StatusNotFound, ""},
+		{"directory", "/css", "", http.StatusNotFound, ""},
+		{"source", "/static.go", "", http.StatusNotFound, ""},
+		{"escape", "/../css/styles.css", "", http.StatusOK, "no-cache"},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		if e.header != "" {
+			req.Header.Set("If-None-Match", e.header)
+		}
+		rr := httptest.NewRecorder()
+
+		s.ServeHTTP(rr, req)
+
+		if rr.Code != e.wantStatus {
+			t.Errorf("%s: got status %d, wanted %d", e.name, rr.Code, e.wantStatus)
+		}
+
+		if got := rr.Header().Get("Cache-Control"); got != e.cacheControl {
+			t.Errorf("%s: got Cache-Control %q, wanted %q", e.name, got, e.cacheControl)
+		}
+	}
+
+	if got := s.Path("css/missing.css"); got != "/static/css/missing.css" {
+		t.Errorf("expected a missing file to get its plain path, got %s", got)
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	f := Embedded()
+
+	for name, check := range map[string]func() error{
+		"template": func() error { _, err := f.Templates.Open("base.layout.tmpl"); return err },
+		"static":   func() error { _, err := f.Static.Open("css/styles.css"); return err },
+		"email":    func() error { _, err := f.Email.Open("basic.html"); return err },
+	} {
+		if err := check(); err != nil {
+			t.Errorf("%s not embedded: %v", name, err)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	if _, err := Dir("../.."); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := Load("/does/not/exist"); err == nil {
+		t.Error("expected an error for a directory that isn't a checkout")
+	}
+}
@@ -0,0 +1,146 @@
+// Package audit describes administrative changes as field by field differences between snapshots
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/models"
+)
+
+// Entities that changes are recorded for
+const (
+	EntityReservation = "reservation"
+	EntityBlock       = "block"
+	EntityRoom        = "room"
+	EntityUser        = "user"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionProcess  = "process"
+	ActionMove     = "move"
+	ActionCheckIn  = "check_in"
+	ActionCheckOut = "check_out"
+	ActionImport   = "import"
+	ActionRestore  = "restore"
+	ActionPurge    = "purge"
+
+	ActionResetPassword = "reset_password"
+)
+
+// Entities and Actions list the values the audit log can be filtered by
+var (
+	Entities = []string{EntityReservation, EntityBlock, EntityRoom, EntityUser}
+	Actions  = []string{ActionCreate, ActionUpdate, ActionDelete, ActionProcess, ActionMove, ActionCheckIn, ActionCheckOut, ActionImport, ActionRestore, ActionPurge, ActionResetPassword}
+)
+
+// Snapshot is the state of an entity as field name to value, nil when the entity does not exist
+type Snapshot map[string]interface{}
+
+const dateLayout = "2006-01-02"
+
+// Reservation is the audited state of a reservation
+func Reservation(res models.Reservation) Snapshot {
+	s := Snapshot{
+		"first_name": res.FirstName,
+		"last_name":  res.LastName,
+		"email":      res.Email,
+		"phone":      res.Phone,
+		"room_id":    res.RoomID,
+		"start_date": res.StartDate.Format(dateLayout),
+		"end_date":   res.EndDate.Format(dateLayout),
+		"processed":  res.Processed,
+	}
+
+	if res.CheckedIn() {
+		s["checked_in_at"] = res.CheckedInAt.Format(time.RFC3339)
+	}
+
+	if res.CheckedOut() {
+		s["checked_out_at"] = res.CheckedOutAt.Format(time.RFC3339)
+	}
+
+	if res.Deleted() {
+		s["deleted_at"] = res.DeletedAt.Format(time.RFC3339)
+	}
+
+	return s
+}
+
+// Block is the audited state of an owner block
+func Block(b models.RoomRestriction) Snapshot {
+	return Snapshot{
+		"room_id":    b.RoomID,
+		"start_date": b.StartDate.Format(dateLayout),
+		"end_date":   b.EndDate.Format(dateLayout),
+	}
+}
+
+// Room is the audited state of a room. The iCal secret is a credential, so only its last characters are kept.
+func Room(room models.Room) Snapshot {
+	return Snapshot{
+		"room_name":   room.RoomName,
+		"ical_secret": fingerprint(room.ICalSecret),
+	}
+}
+
+// User is the audited state of a user, leaving out the password
+func User(u models.User) Snapshot {
+	return Snapshot{
+		"first_name":   u.FirstName,
+		"last_name":    u.LastName,
+		"email":        u.Email,
+		"access_level": u.AccessLevel,
+	}
+}
+
+// fingerprint stands for a secret by the start of its hash, enough to tell a rotation apart from no change
+// without recording anything that works in place of the secret
+func fingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
+
+// Changes lists the fields whose values differ between two snapshots, sorted by field name
+func Changes(before, after Snapshot) []models.AuditChange {
+	fields := make(map[string]bool)
+	for f := range before {
+		fields[f] = true
+	}
+	for f := range after {
+		fields[f] = true
+	}
+
+	var changes []models.AuditChange
+	for f := range fields {
+		b, a := format(before, f), format(after, f)
+		if b != a {
+			changes = append(changes, models.AuditChange{Field: f, Before: b, After: a})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+// format is the value of a field as text, empty when it is missing
+func format(s Snapshot, field string) string {
+	v, ok := s[field]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
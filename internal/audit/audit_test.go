@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/models"
+)
+
+func TestChanges(t *testing.T) {
+	arrival := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+
+	before := models.Reservation{FirstName: "John", LastName: "Smith", Email: "john@here.com", RoomID: 1, StartDate: arrival, EndDate: arrival.AddDate(0, 0, 3)}
+	after := before
+	after.Email = "john@there.com"
+	after.RoomID = 2
+
+	changes := Changes(Reservation(before), Reservation(after))
+
+	want := []models.AuditChange{
+		{Field: "email", Before: "john@here.com", After: "john@there.com"},
+		{Field: "room_id", Before: "1", After: "2"},
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, wanted %d: %+v", len(changes), len(want), changes)
+	}
+
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: got %+v, wanted %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestChangesCreateAndDelete(t *testing.T) {
+	block := Block(models.RoomRestriction{RoomID: 2, StartDate: time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC)})
+
+	created := Changes(nil, block)
+	if len(created) != 3 || created[0].Field != "end_date" || created[0].Before != "" || created[0].After != "2026-11-03" {
+		t.Errorf("unexpected changes for a new block: %+v", created)
+	}
+
+	deleted := Changes(block, nil)
+	if len(deleted) != 3 || deleted[1].Field != "room_id" || deleted[1].Before != "2" || deleted[1].After != "" {
+		t.Errorf("unexpected changes for a deleted block: %+v", deleted)
+	}
+
+	if same := Changes(block, block); len(same) != 0 {
+		t.Errorf("expected no changes between equal snapshots, got %+v", same)
+	}
+}
+
+func TestRoomHidesSecret(t *testing.T) {
+	changes := Changes(Room(models.Room{RoomName: "Suite", ICalSecret: "0123456789abcdef"}), Room(models.Room{RoomName: "Suite", ICalSecret: "fedcba9876543210"}))
+
+	if len(changes) != 1 || changes[0].Field != "ical_secret" || changes[0].Before == changes[0].After {
+		t.Fatalf("unexpected changes for a rotated secret: %+v", changes)
+	}
+
+	for _, v := range []string{changes[0].Before, changes[0].After} {
+		if !strings.HasPrefix(v, "sha256:") || strings.Contains(v, "cdef") || strings.Contains(v, "3210") {
+			t.Errorf("expected a fingerprint of the secret, got %q", v)
+		}
+	}
+}
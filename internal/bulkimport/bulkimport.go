@@ -0,0 +1,257 @@
+// Package bulkimport reads reservations and owner blocks from a CSV file and checks
+// every row before anything is written.
+package bulkimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository"
+)
+
+// Row kinds
+const (
+	KindReservation = "reservation"
+	KindBlock       = "block"
+)
+
+const dateLayout = "2006-01-02"
+
+// MaxRows is the largest file accepted
+const MaxRows = 5000
+
+// Columns lists the CSV header, type, room, start_date and end_date are always required
+var Columns = []string{"type", "first_name", "last_name", "email", "phone", "room", "start_date", "end_date"}
+
+// Row is one line of the file with the problems found in it
+type Row struct {
+	Line        int
+	Kind        string
+	Reservation models.Reservation
+	Errors      []string
+}
+
+// Valid reports whether the row can be imported
+func (r Row) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Report is the result of checking a file
+type Report struct {
+	Rows    []Row
+	Valid   int
+	Invalid int
+}
+
+// Parse reads a CSV file and checks each row on its own: columns, dates and room names.
+// Rooms are matched by name, ignoring case, or by id. It only fails for a file it can't read.
+func Parse(r io.Reader, rooms []models.Room) ([]Row, error) {
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	} else if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+
+	for _, c := range []string{"type", "room", "start_date", "end_date"} {
+		if _, ok := index[c]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", c)
+		}
+	}
+
+	byName := make(map[string]models.Room)
+	byID := make(map[string]models.Room)
+	for _, room := range rooms {
+		byName[strings.ToLower(room.RoomName)] = room
+		byID[strconv.Itoa(room.ID)] = room
+	}
+
+	var rows []Row
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("the file has more than %d rows", MaxRows)
+		}
+
+		get := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := Row{Line: line, Kind: strings.ToLower(get("type"))}
+		res := &row.Reservation
+
+		switch row.Kind {
+		case KindReservation:
+			res.FirstName = get("first_name")
+			res.LastName = get("last_name")
+			res.Email = get("email")
+			res.Phone = get("phone")
+
+			if len(res.FirstName) < 3 {
+				row.Errors = append(row.Errors, "first_name must be at least 3 characters long")
+			}
+			if res.LastName == "" {
+				row.Errors = append(row.Errors, "last_name is required")
+			}
+			if !govalidator.IsEmail(res.Email) {
+				row.Errors = append(row.Errors, "email is not a valid address")
+			}
+		case KindBlock:
+		default:
+			row.Errors = append(row.Errors, `type must be "reservation" or "block"`)
+		}
+
+		room, ok := byName[strings.ToLower(get("room"))]
+		if !ok {
+			room, ok = byID[get("room")]
+		}
+		if ok {
+			res.RoomID = room.ID
+			res.Room = room
+		} else {
+			row.Errors = append(row.Errors, fmt.Sprintf("no room called %q", get("room")))
+		}
+
+		var startErr, endErr error
+		res.StartDate, startErr = time.Parse(dateLayout, get("start_date"))
+		if startErr != nil {
+			row.Errors = append(row.Errors, "start_date must be YYYY-MM-DD")
+		}
+		res.EndDate, endErr = time.Parse(dateLayout, get("end_date"))
+		if endErr != nil {
+			row.Errors = append(row.Errors, "end_date must be YYYY-MM-DD")
+		}
+		if startErr == nil && endErr == nil && !res.EndDate.After(res.StartDate) {
+			row.Errors = append(row.Errors, "end_date must be after start_date")
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Check parses a file and then checks the rows that look right against the rooms'
+// availability and against each other, so two rows can't take the same night.
+func Check(r io.Reader, db repository.DatabaseRepo) (Report, error) {
+
+	var report Report
+
+	rooms, err := db.AllRooms()
+	if err != nil {
+		return report, err
+	}
+
+	rows, err := Parse(r, rooms)
+	if err != nil {
+		return report, err
+	}
+
+	// nights taken by earlier valid rows, per room
+	taken := make(map[int][]models.Reservation)
+
+	for i := range rows {
+		row := &rows[i]
+
+		if row.Valid() {
+			res := row.Reservation
+
+			available, err := db.SearchAvailabilityByDatesByRoomID(res.StartDate, res.EndDate, res.RoomID)
+			if err != nil {
+				return report, err
+			}
+			if !available {
+				row.Errors = append(row.Errors, fmt.Sprintf("%s is not available for these dates", res.Room.RoomName))
+			}
+
+			for _, other := range taken[res.RoomID] {
+				if res.StartDate.Before(other.EndDate) && res.EndDate.After(other.StartDate) {
+					row.Errors = append(row.Errors, "overlaps an earlier row for the same room")
+					break
+				}
+			}
+		}
+
+		if row.Valid() {
+			taken[row.Reservation.RoomID] = append(taken[row.Reservation.RoomID], row.Reservation)
+			report.Valid++
+		} else {
+			report.Invalid++
+		}
+	}
+
+	report.Rows = rows
+
+	return report, nil
+}
+
+// ValidLines lists the line numbers of the valid rows, such as "2,3,5", to tell whether a check
+// of the same file came out differently
+func (report Report) ValidLines() string {
+	var lines []string
+	for _, row := range report.Rows {
+		if row.Valid() {
+			lines = append(lines, strconv.Itoa(row.Line))
+		}
+	}
+	return strings.Join(lines, ",")
+}
+
+// Split turns the valid rows into reservations, and owner blocks with one restriction per night
+// as the reservations calendar expects
+func (report Report) Split() ([]models.Reservation, []models.RoomRestriction) {
+
+	var reservations []models.Reservation
+	var blocks []models.RoomRestriction
+
+	for _, row := range report.Rows {
+		if !row.Valid() {
+			continue
+		}
+
+		res := row.Reservation
+
+		if row.Kind == KindReservation {
+			reservations = append(reservations, res)
+			continue
+		}
+
+		for d := res.StartDate; d.Before(res.EndDate); d = d.AddDate(0, 0, 1) {
+			blocks = append(blocks, models.RoomRestriction{
+				StartDate:     d,
+				EndDate:       d.AddDate(0, 0, 1),
+				RoomID:        res.RoomID,
+				RestrictionID: 2,
+			})
+		}
+	}
+
+	return reservations, blocks
+}
@@ -0,0 +1,107 @@
+package bulkimport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/repository/dbrepo"
+)
+
+var testFile = `type,first_name,last_name,email,phone,room,start_date,end_date
+reservation,John,Smith,john@here.com,555-1234,General's Quarters,2026-01-05,2026-01-08
+reservation,Jane,Doe,jane@here.com,,major's suite,2026-01-05,2026-01-07
+block,,,,,3,2026-01-06,2026-01-10
+reservation,Al,Nope,not-an-email,,Penthouse,2026-01-09,2026-01-09
+reservation,Bobby,Tables,bobby@here.com,,General's Quarters,2026-01-07,2026-01-09
+block,,,,,Fully Booked Room,2026-02-01,2026-02-02
+holiday,,,,,3,2026-03-01,2026-03-02
+`
+
+func TestCheck(t *testing.T) {
+	var app config.AppConfig
+
+	report, err := Check(strings.NewReader(testFile), dbrepo.NewTestRepo(&app))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Valid != 2 || report.Invalid != 5 {
+		t.Errorf("expected 2 valid and 5 invalid rows, got %d and %d", report.Valid, report.Invalid)
+	}
+
+	tests := []struct {
+		line  int
+		valid bool
+		error string
+	}{
+		{2, true, ""},
+		{3, true, ""},
+		{4, false, "overlaps an earlier row"},
+		{5, false, "no room called \"Penthouse\""},
+		{6, false, "overlaps an earlier row"},
+		{7, false, "not available"},
+		{8, false, "type must be"},
+	}
+
+	for i, e := range tests {
+		row := report.Rows[i]
+
+		if row.Line != e.line || row.Valid() != e.valid {
+			t.Errorf("row %d: expected line %d valid %v, got line %d errors %v", i, e.line, e.valid, row.Line, row.Errors)
+			continue
+		}
+
+		if e.error != "" && !strings.Contains(strings.Join(row.Errors, "; "), e.error) {
+			t.Errorf("line %d: expected an error containing %q, got %v", e.line, e.error, row.Errors)
+		}
+	}
+
+	if errs := report.Rows[3].Errors; len(errs) != 4 {
+		t.Errorf("expected every problem on line 5 to be reported, got %v", errs)
+	}
+
+	if lines := report.ValidLines(); lines != "2,3" {
+		t.Errorf("expected lines 2 and 3 to be valid, got %q", lines)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	var app config.AppConfig
+
+	file := `Type,Room,Start_Date,End_Date,First_Name,Last_Name,Email
+block,2,2026-01-05,2026-01-08,,,
+reservation,3,2026-01-05,2026-01-08,Jane,Doe,jane@here.com
+`
+
+	report, err := Check(strings.NewReader(file), dbrepo.NewTestRepo(&app))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reservations, blocks := report.Split()
+
+	if len(reservations) != 1 || reservations[0].LastName != "Doe" {
+		t.Errorf("unexpected reservations %+v", reservations)
+	}
+
+	if len(blocks) != 3 {
+		t.Fatalf("expected a block per night, got %d", len(blocks))
+	}
+
+	for _, b := range blocks {
+		if b.RoomID != 2 || b.RestrictionID != 2 || b.EndDate.Sub(b.StartDate).Hours() != 24 {
+			t.Errorf("unexpected block %+v", b)
+		}
+	}
+}
+
+func TestParseRejectsBadHeader(t *testing.T) {
+	if _, err := Parse(strings.NewReader("name,arrival\nJohn,2026-01-01\n"), nil); err == nil {
+		t.Error("expected an error for a file without the required columns")
+	}
+
+	if _, err := Parse(strings.NewReader(""), nil); err == nil {
+		t.Error("expected an error for an empty file")
+	}
+}
@@ -2,18 +2,29 @@ package config
 
 import (
 	"html/template"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/gummy789j/bookings/internal/assets"
+	"github.com/gummy789j/bookings/internal/health"
+	"github.com/gummy789j/bookings/internal/metrics"
 	"github.com/gummy789j/bookings/internal/models"
 )
 
 type AppConfig struct {
-	UseCache      bool                          //是否開啟快取修改的功能
-	TemplateCache map[string]*template.Template //以name為Key存放每一個new page Template
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
-	InProduction  bool
-	Session       *scs.SessionManager
-	MailChan      chan models.MailData
+	Settings       Settings                      //what the app was started with
+	UseCache       bool                          //是否開啟快取修改的功能
+	TemplateCache  map[string]*template.Template //以name為Key存放每一個new page Template
+	Assets         assets.Files                  //templates, static files and email templates, embedded or from disk
+	Static         *assets.Static                //serves the static files under content hashed URLs
+	Logger         *slog.Logger                  //structured logger, JSON in production
+	Metrics        *metrics.Metrics              //counters scraped by Prometheus, nil when not collected
+	Health         *health.Checker               //readiness checks answered on /readyz
+	InProduction   bool
+	Session        *scs.SessionManager
+	MailChan       chan models.MailData
+	WebhookChan    chan models.WebhookEvent
+	ICalInterval   time.Duration //how often external iCal feeds are imported
+	TrashRetention time.Duration //how long deleted reservations are kept before they are purged
 }
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/driver"
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a setting that must never be printed, such as a password. Formatting it shows a mask instead.
+type Secret string
+
+const redacted = "****"
+
+// String masks the secret
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString masks the secret in %#v too
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// LogValue masks the secret in structured logs
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// DBSettings are the database connection settings
+type DBSettings struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	SSLMode  string `yaml:"sslmode"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	PingTimeout     time.Duration `yaml:"ping_timeout"`
+	HealthInterval  time.Duration `yaml:"health_interval"`
+}
+
+// DSN is the connection string for the database
+func (db DBSettings) DSN() string {
+	return fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=%s",
+		db.Host, db.Port, db.Name, db.User, string(db.Password), db.SSLMode)
+}
+
+// Options are the pool limits and connection timeouts
+func (db DBSettings) Options() driver.Options {
+	return driver.Options{
+		MaxOpenConns:    db.MaxOpenConns,
+		MaxIdleConns:    db.MaxIdleConns,
+		ConnMaxLifetime: db.ConnMaxLifetime,
+		ConnMaxIdleTime: db.ConnMaxIdleTime,
+		ConnectTimeout:  db.ConnectTimeout,
+		PingTimeout:     db.PingTimeout,
+	}
+}
+
+// SMTPSettings are the settings of the mail server notifications are sent through
+type SMTPSettings struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password Secret `yaml:"password"`
+}
+
+// Settings is the configuration the application is started with
+type Settings struct {
+	Port            int           `yaml:"port"`
+	InProduction    bool          `yaml:"production"`
+	UseCache        bool          `yaml:"cache"`
+	SessionLifetime time.Duration `yaml:"session_lifetime"`
+	ICalInterval    time.Duration `yaml:"ical_interval"`
+	TrashRetention  time.Duration `yaml:"trash_retention"`
+	MigrateOnStart  bool          `yaml:"migrate_on_startup"`
+	AssetsDir       string        `yaml:"assets_dir"`
+	LogLevel        string        `yaml:"log_level"`
+	MetricsPort     int           `yaml:"metrics_port"`
+	DB              DBSettings    `yaml:"database"`
+	SMTP            SMTPSettings  `yaml:"smtp"`
+
+	// Args are the command line arguments after the flags, naming a command to run instead of the server
+	Args []string `yaml:"-"`
+}
+
+// Defaults are the settings used for anything not configured otherwise
+func Defaults() Settings {
+	pool := driver.DefaultOptions()
+
+	return Settings{
+		Port:            8081,
+		InProduction:    true,
+		UseCache:        true,
+		SessionLifetime: 24 * time.Hour,
+		ICalInterval:    30 * time.Minute,
+		TrashRetention:  30 * 24 * time.Hour,
+		LogLevel:        "info",
+		MetricsPort:     9091,
+		DB: DBSettings{
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    pool.MaxOpenConns,
+			MaxIdleConns:    pool.MaxIdleConns,
+			ConnMaxLifetime: pool.ConnMaxLifetime,
+			ConnMaxIdleTime: pool.ConnMaxIdleTime,
+			ConnectTimeout:  pool.ConnectTimeout,
+			PingTimeout:     pool.PingTimeout,
+			HealthInterval:  30 * time.Second,
+		},
+		SMTP: SMTPSettings{
+			Host: "localhost",
+			Port: 1025,
+		},
+	}
+}
+
+// Level is the lowest level logged
+func (s Settings) Level() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s.LogLevel))
+	return level, err
+}
+
+// Addr is the address the server listens on
+func (s Settings) Addr() string {
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+// MetricsAddr is the address metrics are served on, kept off the public port
+func (s Settings) MetricsAddr() string {
+	return fmt.Sprintf(":%d", s.MetricsPort)
+}
+
+// option ties a setting to its command line flag and environment variable. The key is its place in the config file.
+type option struct {
+	flag  string
+	env   string
+	key   string
+	usage string
+	ptr   interface{}
+}
+
+func (s *Settings) options() []option {
+	return []option{
+		{"port", "BOOKINGS_PORT", "port", "Port to listen on", &s.Port},
+		{"production", "BOOKINGS_PRODUCTION", "production", "Application is in production", &s.InProduction},
+		{"cache", "BOOKINGS_CACHE", "cache", "Use template cache", &s.UseCache},
+		{"sessionlifetime", "BOOKINGS_SESSION_LIFETIME", "session_lifetime", "How long a login lasts", &s.SessionLifetime},
+		{"icalinterval", "BOOKINGS_ICAL_INTERVAL", "ical_interval", "How often external iCal feeds are imported", &s.ICalInterval},
+		{"trashretention", "BOOKINGS_TRASH_RETENTION", "trash_retention", "How long deleted reservations are kept, 0 keeps them forever", &s.TrashRetention},
+		{"migrate", "BOOKINGS_MIGRATE_ON_STARTUP", "migrate_on_startup", "Apply pending database migrations on startup", &s.MigrateOnStart},
+		{"assets", "BOOKINGS_ASSETS_DIR", "assets_dir", "Read templates and static files from this checkout of the repository instead of the binary, for editing them live", &s.AssetsDir},
+		{"loglevel", "BOOKINGS_LOG_LEVEL", "log_level", "Lowest level logged: debug, info, warn or error", &s.LogLevel},
+		{"metricsport", "BOOKINGS_METRICS_PORT", "metrics_port", "Port serving /metrics to Prometheus, 0 turns metrics off", &s.MetricsPort},
+		{"dbhost", "BOOKINGS_DB_HOST", "database.host", "Database host", &s.DB.Host},
+		{"dbport", "BOOKINGS_DB_PORT", "database.port", "Database port", &s.DB.Port},
+		{"dbname", "BOOKINGS_DB_NAME", "database.name", "Database name", &s.DB.Name},
+		{"dbuser", "BOOKINGS_DB_USER", "database.user", "Database user", &s.DB.User},
+		{"dbpwd", "BOOKINGS_DB_PASSWORD", "database.password", "Database password", &s.DB.Password},
+		{"dbssl", "BOOKINGS_DB_SSLMODE", "database.sslmode", "Database ssl settings", &s.DB.SSLMode},
+		{"dbmaxopen", "BOOKINGS_DB_MAX_OPEN_CONNS", "database.max_open_conns", "Database connections open at once, 0 for no limit", &s.DB.MaxOpenConns},
+		{"dbmaxidle", "BOOKINGS_DB_MAX_IDLE_CONNS", "database.max_idle_conns", "Database connections kept open while idle", &s.DB.MaxIdleConns},
+		{"dblifetime", "BOOKINGS_DB_CONN_MAX_LIFETIME", "database.conn_max_lifetime", "How long a database connection is used before it is replaced, 0 keeps it", &s.DB.ConnMaxLifetime},
+		{"dbidletime", "BOOKINGS_DB_CONN_MAX_IDLE_TIME", "database.conn_max_idle_time", "How long an idle database connection is kept, 0 keeps it", &s.DB.ConnMaxIdleTime},
+		{"dbconnecttimeout", "BOOKINGS_DB_CONNECT_TIMEOUT", "database.connect_timeout", "How long to keep retrying while the database comes up, 0 tries once", &s.DB.ConnectTimeout},
+		{"dbpingtimeout", "BOOKINGS_DB_PING_TIMEOUT", "database.ping_timeout", "How long each connection attempt and health check may take", &s.DB.PingTimeout},
+		{"dbhealthinterval", "BOOKINGS_DB_HEALTH_INTERVAL", "database.health_interval", "How often the database connection is checked, 0 turns the checks off", &s.DB.HealthInterval},
+		{"smtphost", "BOOKINGS_SMTP_HOST", "smtp.host", "Mail server host", &s.SMTP.Host},
+		{"smtpport", "BOOKINGS_SMTP_PORT", "smtp.port", "Mail server port", &s.SMTP.Port},
+		{"smtpuser", "BOOKINGS_SMTP_USERNAME", "smtp.username", "Mail server user", &s.SMTP.Username},
+		{"smtppwd", "BOOKINGS_SMTP_PASSWORD", "smtp.password", "Mail server password", &s.SMTP.Password},
+	}
+}
+
+// flagSet binds every option of s to a flag, keeping the current values as their defaults
+func (s *Settings) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	for _, o := range s.options() {
+		switch p := o.ptr.(type) {
+		case *string:
+			fs.StringVar(p, o.flag, *p, o.usage)
+		case *Secret:
+			fs.StringVar((*string)(p), o.flag, string(*p), o.usage)
+		case *int:
+			fs.IntVar(p, o.flag, *p, o.usage)
+		case *bool:
+			fs.BoolVar(p, o.flag, *p, o.usage)
+		case *time.Duration:
+			fs.DurationVar(p, o.flag, *p, o.usage)
+		}
+	}
+
+	fs.String("config", "", "Configuration file, also read from BOOKINGS_CONFIG")
+
+	return fs
+}
+
+// Load builds the settings from, in increasing precedence, the defaults, a YAML config file, environment
+// variables and command line flags. The file is named by -config or BOOKINGS_CONFIG and is optional.
+// getenv is os.Getenv outside of tests.
+func Load(name string, args []string, getenv func(string) string) (Settings, error) {
+	s := Defaults()
+	fs := s.flagSet(name)
+
+	// flags come last, so only note the ones given and put them back on top at the end
+	if err := fs.Parse(args); err != nil {
+		return s, err
+	}
+
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	s = Defaults()
+
+	path := given["config"]
+	if path == "" {
+		path = getenv("BOOKINGS_CONFIG")
+	}
+
+	if path != "" {
+		if err := s.readFile(path); err != nil {
+			return s, err
+		}
+	}
+
+	for _, o := range s.options() {
+		if v := getenv(o.env); v != "" {
+			if err := fs.Set(o.flag, v); err != nil {
+				return s, fmt.Errorf("invalid value for %s: %v", o.env, err)
+			}
+		}
+	}
+
+	for name, v := range given {
+		if err := fs.Set(name, v); err != nil {
+			return s, fmt.Errorf("invalid value for -%s: %v", name, err)
+		}
+	}
+
+	s.Args = fs.Args()
+
+	return s, s.Validate()
+}
+
+// LoadFromEnvironment loads the settings for the running program from its flags and environment
+func LoadFromEnvironment() (Settings, error) {
+	return Load(os.Args[0], os.Args[1:], os.Getenv)
+}
+
+// readFile overlays the settings in a YAML file, rejecting keys it doesn't know
+func (s *Settings) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %v", err)
+	}
+
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	// an empty file leaves everything as it was
+	if err := dec.Decode(s); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks that the settings can be started with, listing every problem at once
+func (s Settings) Validate() error {
+	var problems []string
+
+	require := func(ok bool, key, problem string) {
+		if ok {
+			return
+		}
+		for _, o := range s.options() {
+			if o.key == key {
+				problems = append(problems, fmt.Sprintf("%s (set -%s, %s or %s in the config file)", problem, o.flag, o.env, o.key))
+				return
+			}
+		}
+	}
+
+	require(s.DB.Name != "", "database.name", "database name is required")
+	require(s.DB.User != "", "database.user", "database user is required")
+	require(validPort(s.Port), "port", "port must be between 1 and 65535")
+	require(s.MetricsPort == 0 || validPort(s.MetricsPort) && s.MetricsPort != s.Port, "metrics_port", "metrics port must be between 1 and 65535 and not the same as port, or 0")
+	require(validPort(s.DB.Port), "database.port", "database port must be between 1 and 65535")
+	require(validPort(s.SMTP.Port), "smtp.port", "mail server port must be between 1 and 65535")
+	require(s.DB.MaxOpenConns >= 0, "database.max_open_conns", "database connection limit can't be negative")
+	require(s.DB.MaxIdleConns >= 0, "database.max_idle_conns", "idle database connection limit can't be negative")
+	require(s.DB.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "database connection lifetime can't be negative")
+	require(s.DB.ConnMaxIdleTime >= 0, "database.conn_max_idle_time", "database connection idle time can't be negative")
+	require(s.DB.ConnectTimeout >= 0, "database.connect_timeout", "database connect timeout can't be negative")
+	require(s.DB.PingTimeout > 0, "database.ping_timeout", "database ping timeout must be positive")
+	require(s.DB.HealthInterval >= 0, "database.health_interval", "database health check interval can't be negative")
+	require(s.SessionLifetime > 0, "session_lifetime", "session lifetime must be positive")
+	require(s.ICalInterval > 0, "ical_interval", "iCal import interval must be positive")
+	require(s.TrashRetention >= 0, "trash_retention", "trash retention can't be negative")
+	_, levelErr := s.Level()
+	require(levelErr == nil, "log_level", "log level must be debug, info, warn or error")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func validPort(p int) bool {
+	return p > 0 && p < 65536
+}
+
+// String lists the settings one per line with secrets masked, so it is safe to log
+func (s Settings) String() string {
+	var b strings.Builder
+
+	for _, o := range s.options() {
+		fmt.Fprintf(&b, "%s: %v\n", o.key, o.value())
+	}
+
+	return b.String()
+}
+
+// LogValue is the settings as a group of attributes with secrets masked
+func (s Settings) LogValue() slog.Value {
+	var attrs []slog.Attr
+
+	for _, o := range s.options() {
+		attrs = append(attrs, slog.Any(o.key, o.value()))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// value is the current value of the setting
+func (o option) value() interface{} {
+	switch p := o.ptr.(type) {
+	case *string:
+		return *p
+	case *Secret:
+		return *p
+	case *int:
+		return *p
+	case *bool:
+		return *p
+	case *time.Duration:
+		return *p
+	}
+	return nil
+}
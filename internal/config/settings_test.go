@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "bookings-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "bookings.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+port: 9000
+session_lifetime: 2h
+database:
+  host: db.internal
+  name: from_file
+  user: from_file
+  password: file-secret
+smtp:
+  port: 2525
+`)
+
+	s, err := Load("bookings", []string{"-config", path, "-dbname", "from_flag"}, env(map[string]string{
+		"BOOKINGS_DB_NAME": "from_env",
+		"BOOKINGS_DB_USER": "from_env",
+		"BOOKINGS_PORT":    "9100",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"default", s.DB.Port, 5432},
+		{"default", s.ICalInterval, 30 * time.Minute},
+		{"file", s.DB.Host, "db.internal"},
+		{"file", s.SessionLifetime, 2 * time.Hour},
+		{"file", s.SMTP.Port, 2525},
+		{"file", string(s.DB.Password), "file-secret"},
+		{"env over file", s.DB.User, "from_env"},
+		{"env over file", s.Port, 9100},
+		{"flag over env", s.DB.Name, "from_flag"},
+	}
+
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, wanted %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadConfigFromEnvironment(t *testing.T) {
+	path := writeConfig(t, "database:\n  name: bookings\n  user: postgres\n")
+
+	s, err := Load("bookings", nil, env(map[string]string{"BOOKINGS_CONFIG": path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.DB.Name != "bookings" {
+		t.Errorf("expected the file named by BOOKINGS_CONFIG to be read, got database name %q", s.DB.Name)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	required := env(map[string]string{"BOOKINGS_DB_NAME": "bookings", "BOOKINGS_DB_USER": "postgres"})
+
+	tests := []struct {
+		name   string
+		args   []string
+		getenv func(string) string
+		config string
+		want   string
+	}{
+		{"missing required", nil, env(nil), "", "database name is required (set -dbname, BOOKINGS_DB_NAME or database.name in the config file); database user is required"},
+		{"bad env", nil, env(map[string]string{"BOOKINGS_DB_NAME": "b", "BOOKINGS_DB_USER": "u", "BOOKINGS_PORT": "eighty"}), "", "invalid value for BOOKINGS_PORT"},
+		{"bad flag", []string{"-icalinterval", "often"}, required, "", "invalid value \"often\" for flag -icalinterval"},
+		{"out of range", []string{"-port", "70000"}, required, "", "port must be between 1 and 65535"},
+		{"negative pool limit", []string{"-dbmaxopen", "-1"}, required, "", "database connection limit can't be negative (set -dbmaxopen, BOOKINGS_DB_MAX_OPEN_CONNS or database.max_open_conns in the config file)"},
+		{"metrics on the site port", []string{"-metricsport", "8081"}, required, "", "metrics port must be between 1 and 65535 and not the same as port"},
+		{"unknown key", nil, required, "databse:\n  name: bookings\n", "field databse not found"},
+		{"missing file", []string{"-config", "/does/not/exist.yml"}, required, "", "cannot read config file"},
+	}
+
+	for _, e := range tests {
+		args := e.args
+		if e.config != "" {
+			args = append([]string{"-config", writeConfig(t, e.config)}, args...)
+		}
+
+		_, err := Load("bookings", args, e.getenv)
+		if err == nil || !strings.Contains(err.Error(), e.want) {
+			t.Errorf("%s: got error %v, wanted one containing %q", e.name, err, e.want)
+		}
+	}
+}
+
+func TestSettingsRedactSecrets(t *testing.T) {
+	s := Defaults()
+	s.DB.Password = "db-secret"
+	s.SMTP.Password = "smtp-secret"
+
+	for _, out := range []string{s.String(), fmt.Sprintf("%v", s), fmt.Sprintf("%+v", s.DB), fmt.Sprintf("%#v", s.SMTP)} {
+		if strings.Contains(out, "secret") {
+			t.Errorf("secret leaked in %q", out)
+		}
+	}
+
+	if !strings.Contains(s.String(), "database.password: ****") {
+		t.Errorf("expected the masked password to be listed, got %q", s.String())
+	}
+
+	if !strings.Contains(s.DB.DSN(), "password=db-secret") {
+		t.Error("expected the connection string to carry the real password")
+	}
+}
+
+func TestLoadCommandArgs(t *testing.T) {
+	s, err := Load("bookings", []string{"-dbname", "bookings", "-dbuser", "postgres", "migrate", "down", "2"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(s.Args, " ") != "migrate down 2" {
+		t.Errorf("got command %q", s.Args)
+	}
+}
@@ -1,64 +1,159 @@
 package driver
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/gummy789j/bookings/internal/logging"
 	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
+// DB holds the database connection pool
 type DB struct {
 	SQL *sql.DB
-}
 
-var dbConn = &DB{}
+	// idle is the idle connection limit, put back after the idle connections are dropped
+	idle int
+}
 
-const maxOpenDbConn = 10
-const maxIdleDbConn = 5
-const maxDbLifetime = 5 * time.Minute
+// Options are the limits of the pool and how hard to try connecting
+type Options struct {
+	MaxOpenConns    int           // connections open at once, 0 for no limit
+	MaxIdleConns    int           // connections kept open while idle
+	ConnMaxLifetime time.Duration // connections are closed and replaced after this long, 0 keeps them
+	ConnMaxIdleTime time.Duration // idle connections are closed after this long, 0 keeps them
+	ConnectTimeout  time.Duration // how long to keep retrying while the database comes up, 0 tries once
+	PingTimeout     time.Duration // how long each attempt and health check may take
+}
 
-// DB holds the database connection pool
-func ConnectSQL(dsn string) (*DB, error) {
-	d, err := NewDatabase(dsn)
-	if err != nil {
-		panic(err)
+// DefaultOptions are the pool settings used unless configured otherwise
+func DefaultOptions() Options {
+	return Options{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 5 * time.Minute,
+		ConnMaxIdleTime: 0,
+		ConnectTimeout:  30 * time.Second,
+		PingTimeout:     5 * time.Second,
 	}
+}
 
-	d.SetMaxOpenConns(maxOpenDbConn)
-	d.SetMaxIdleConns(maxIdleDbConn)
-	d.SetConnMaxLifetime(maxDbLifetime)
-
-	dbConn.SQL = d
+const (
+	// firstRetryDelay is the wait after the first failed attempt, doubling after each one up to maxRetryDelay
+	firstRetryDelay = 500 * time.Millisecond
+	maxRetryDelay   = 5 * time.Second
+)
 
-	err = testDB(d)
+// ConnectSQL opens the pool and waits for the database to answer, retrying with back-off for up to
+// opts.ConnectTimeout since it may still be starting, such as when started together by docker compose. Failed
+// attempts are logged to logger.
+func ConnectSQL(ctx context.Context, dsn string, opts Options, logger *slog.Logger) (*DB, error) {
+	d, err := NewDatabase(dsn, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return dbConn, nil
+	deadline := time.Now().Add(opts.ConnectTimeout)
+
+	for attempt := 1; ; attempt++ {
+		err = ping(ctx, d.SQL, opts.PingTimeout)
+		if err == nil {
+			return d, nil
+		}
+
+		wait := retryDelay(attempt)
+		if time.Now().Add(wait).After(deadline) {
+			d.SQL.Close()
+			return nil, fmt.Errorf("database not answering after %d attempts: %v", attempt, err)
+		}
+
+		logger.Warn("database not answering, retrying", slog.Int("attempt", attempt), slog.Duration("wait", wait), logging.Err(err))
 
+		select {
+		case <-ctx.Done():
+			d.SQL.Close()
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
 }
 
-// try to ping the database
-func testDB(d *sql.DB) error {
-	err := d.Ping()
-	if err != nil {
-		return err
+// retryDelay is how long to wait after a number of failed attempts
+func retryDelay(attempt int) time.Duration {
+	wait := firstRetryDelay
+	for i := 1; i < attempt && wait < maxRetryDelay; i++ {
+		wait *= 2
 	}
 
-	return nil
+	if wait > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return wait
 }
 
-// build a new database connection
-func NewDatabase(dsn string) (*sql.DB, error) {
+// NewDatabase opens a pool with the limits of opts, without connecting yet
+func NewDatabase(dsn string, opts Options) (*DB, error) {
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
-		return nil, err
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	return &DB{SQL: db, idle: opts.MaxIdleConns}, nil
+}
+
+// Watch checks the database every interval until ctx is done, logging when it stops and starts answering again.
+// While it doesn't answer the idle connections are dropped, so that once it is back the pool reconnects rather
+// than handing out connections to the server that went away.
+func (d *DB) Watch(ctx context.Context, interval, timeout time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	down := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := ping(ctx, d.SQL, timeout)
+
+		switch {
+		case err != nil:
+			if !down {
+				logger.Error("lost the database connection", logging.Err(err))
+			}
+			down = true
+			d.dropIdle()
+		case down:
+			logger.Info("database connection restored")
+			down = false
+		}
+	}
+}
+
+// dropIdle closes the idle connections, keeping the idle limit for the ones opened from now on
+func (d *DB) dropIdle() {
+	d.SQL.SetMaxIdleConns(0)
+	d.SQL.SetMaxIdleConns(d.idle)
+}
+
+// ping checks that the database answers within timeout
+func ping(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	return db, nil
+	return db.PingContext(ctx)
 }
@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/logging"
+)
+
+// unreachable is a database nothing listens for, so every attempt fails at once
+const unreachable = "host=127.0.0.1 port=1 dbname=bookings user=postgres"
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{
+		500 * time.Millisecond,
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+		5 * time.Second,
+	}
+
+	for i, w := range want {
+		if got := retryDelay(i + 1); got != w {
+			t.Errorf("attempt %d: expected to wait %s, got %s", i+1, w, got)
+		}
+	}
+}
+
+func TestNewDatabaseAppliesOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxOpenConns = 7
+
+	d, err := NewDatabase(unreachable, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.SQL.Close()
+
+	if got := d.SQL.Stats().MaxOpenConnections; got != 7 {
+		t.Errorf("expected at most 7 connections, got %d", got)
+	}
+}
+
+func TestConnectSQLGivesUp(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PingTimeout = time.Second
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, false, slog.LevelInfo)
+
+	// without a timeout there is a single attempt
+	opts.ConnectTimeout = 0
+	_, err := ConnectSQL(context.Background(), unreachable, opts, logger)
+	if err == nil || !strings.Contains(err.Error(), "after 1 attempts") {
+		t.Errorf("expected to give up after one attempt, got %v", err)
+	}
+
+	// the first retry waits 500ms and the second a second, which would be past the timeout
+	opts.ConnectTimeout = 1200 * time.Millisecond
+	start := time.Now()
+	_, err = ConnectSQL(context.Background(), unreachable, opts, logger)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("expected to give up after two attempts, got %v", err)
+	}
+	if took := time.Since(start); took > opts.ConnectTimeout {
+		t.Errorf("expected to give up within the timeout, took %s", took)
+	}
+
+	if !strings.Contains(buf.String(), "database not answering, retrying") || !strings.Contains(buf.String(), "attempt=1") {
+		t.Errorf("expected the retry to be logged, got %q", buf.String())
+	}
+}
+
+func TestConnectSQLStopsWithContext(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PingTimeout = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := ConnectSQL(ctx, unreachable, opts, logging.Discard())
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+}
+
+func TestWatchLogsOutageOnce(t *testing.T) {
+	d, err := NewDatabase(unreachable, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.SQL.Close()
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, false, slog.LevelInfo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// returns once ctx is done
+	d.Watch(ctx, 10*time.Millisecond, time.Second, logger)
+
+	if n := strings.Count(buf.String(), "lost the database connection"); n != 1 {
+		t.Errorf("expected the outage to be logged once, got %d times in %q", n, buf.String())
+	}
+
+	if got := d.SQL.Stats().Idle; got != 0 {
+		t.Errorf("expected no idle connections, got %d", got)
+	}
+}
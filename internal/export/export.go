@@ -0,0 +1,150 @@
+// Package export writes tabular data as CSV or XLSX files, one row at a time, so
+// large exports never have to be held in memory.
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RowWriter writes rows of cells, Close must be called to finish the file
+type RowWriter interface {
+	Write(row []string) error
+	Close() error
+}
+
+// csvWriter writes CSV that Excel opens correctly
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSV returns a writer for a CSV file. A UTF-8 byte order mark is written first so
+// Excel detects the encoding, and cells that Excel would run as a formula are quoted.
+func NewCSV(w io.Writer) (RowWriter, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) Write(row []string) error {
+	safe := make([]string, len(row))
+	for i, cell := range row {
+		safe[i] = neutralize(cell)
+	}
+	return c.w.Write(safe)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// neutralize stops spreadsheet apps from treating user entered text as a formula
+func neutralize(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// xlsxWriter writes a single sheet workbook with every cell as text
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	name  string
+	rows  int
+}
+
+// NewXLSX returns a writer for an Excel workbook with one sheet called name
+func NewXLSX(w io.Writer, name string) (RowWriter, error) {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	x := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(f), name: name}
+
+	_, err = x.sheet.WriteString(xml.Header +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+func (x *xlsxWriter) Write(row []string) error {
+	x.rows++
+
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows)
+	for i, cell := range row {
+		fmt.Fprintf(x.sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, column(i), x.rows)
+		if err := xml.EscapeText(x.sheet, []byte(cell)); err != nil {
+			return err
+		}
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := x.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+
+	var name strings.Builder
+	if err := xml.EscapeText(&name, []byte(x.name)); err != nil {
+		return err
+	}
+
+	parts := []struct{ path, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+
+	for _, p := range parts {
+		f, err := x.zw.Create(p.path)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header+p.body); err != nil {
+			return err
+		}
+	}
+
+	return x.zw.Close()
+}
+
+// column is the spreadsheet letter of a zero based column index, 0 is A and 26 is AA
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
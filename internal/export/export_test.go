@@ -0,0 +1,79 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := NewCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = w.Write([]string{"ID", "Name"})
+	_ = w.Write([]string{"1", "Smith, John"})
+	_ = w.Write([]string{"2", "=HYPERLINK(\"x\")"})
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "\ufeffID,Name\n1,\"Smith, John\"\n2,\"'=HYPERLINK(\"\"x\"\")\"\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := NewXLSX(&buf, "Reservations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = w.Write([]string{"ID", "Name"})
+	_ = w.Write([]string{"1", "Tom & Jerry <3"})
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(sheet, `<c r="B2" t="inlineStr"><is><t xml:space="preserve">Tom &amp; Jerry &lt;3</t></is></c>`) {
+		t.Errorf("unexpected sheet %s", sheet)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != expected {
+			t.Errorf("column(%d) = %s, expected %s", i, got, expected)
+		}
+	}
+}
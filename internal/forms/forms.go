@@ -3,7 +3,9 @@ package forms
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 )
@@ -61,3 +63,24 @@ func (f *Forms) IsEmail(field string) {
 		f.Errors.Add(field, "Invalid email address")
 	}
 }
+
+// IsDate checks that a field holds a date in YYYY-MM-DD format
+func (f *Forms) IsDate(field string) bool {
+	if _, err := time.Parse("2006-01-02", f.Get(field)); err != nil {
+		f.Errors.Add(field, "Invalid date, must be YYYY-MM-DD")
+		return false
+	}
+
+	return true
+}
+
+// IsInt checks that a field holds a positive whole number
+func (f *Forms) IsInt(field string) bool {
+	x, err := strconv.Atoi(f.Get(field))
+	if err != nil || x < 1 {
+		f.Errors.Add(field, "This field must be a positive number")
+		return false
+	}
+
+	return true
+}
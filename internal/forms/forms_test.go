@@ -110,3 +110,49 @@ func TestForm_IsEmail(t *testing.T) {
 	}
 
 }
+
+func TestForm_IsDate(t *testing.T) {
+
+	postedData := url.Values{}
+	postedData.Add("start", "2050-13-01")
+	form := New(postedData)
+
+	form.IsDate("start")
+
+	if form.Valid() {
+		t.Error("form shows valid date when it should not be valid")
+	}
+
+	postedData = url.Values{}
+	postedData.Add("start", "2050-01-01")
+	form = New(postedData)
+
+	form.IsDate("start")
+
+	if !form.Valid() {
+		t.Error("form shows invalid date when it should be valid")
+	}
+}
+
+func TestForm_IsInt(t *testing.T) {
+
+	postedData := url.Values{}
+	postedData.Add("room_id", "abc")
+	form := New(postedData)
+
+	form.IsInt("room_id")
+
+	if form.Valid() {
+		t.Error("form shows valid number when it should not be valid")
+	}
+
+	postedData = url.Values{}
+	postedData.Add("room_id", "2")
+	form = New(postedData)
+
+	form.IsInt("room_id")
+
+	if !form.Valid() {
+		t.Error("form shows invalid number when it should be valid")
+	}
+}
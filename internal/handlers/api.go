@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/webhooks"
+)
+
+const apiDateLayout = "2006-01-02"
+
+// roomResponse is a room as returned by the JSON API
+type roomResponse struct {
+	ID       int    `json:"id"`
+	RoomName string `json:"room_name"`
+}
+
+// reservationResponse is a reservation as returned by the JSON API
+type reservationResponse struct {
+	ID        int          `json:"id"`
+	FirstName string       `json:"first_name"`
+	LastName  string       `json:"last_name"`
+	Email     string       `json:"email"`
+	Phone     string       `json:"phone"`
+	StartDate string       `json:"start_date"`
+	EndDate   string       `json:"end_date"`
+	RoomID    int          `json:"room_id"`
+	Processed bool         `json:"processed"`
+	Room      roomResponse `json:"room"`
+}
+
+// availabilityResponse is the result of an availability query
+type availabilityResponse struct {
+	StartDate string         `json:"start_date"`
+	EndDate   string         `json:"end_date"`
+	RoomID    int            `json:"room_id,omitempty"`
+	Available *bool          `json:"available,omitempty"`
+	Rooms     []roomResponse `json:"rooms,omitempty"`
+}
+
+// reservationRequest is the body accepted when creating a reservation
+type reservationRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	RoomID    int    `json:"room_id"`
+}
+
+func newRoomResponse(room models.Room) roomResponse {
+	return roomResponse{
+		ID:       room.ID,
+		RoomName: room.RoomName,
+	}
+}
+
+func newReservationResponse(res models.Reservation) reservationResponse {
+	return reservationResponse{
+		ID:        res.ID,
+		FirstName: res.FirstName,
+		LastName:  res.LastName,
+		Email:     res.Email,
+		Phone:     res.Phone,
+		StartDate: res.StartDate.Format(apiDateLayout),
+		EndDate:   res.EndDate.Format(apiDateLayout),
+		RoomID:    res.RoomID,
+		Processed: res.Processed == 1,
+		Room:      newRoomResponse(res.Room),
+	}
+}
+
+// validateDateRange checks start and end fields and returns them parsed
+func validateDateRange(form *forms.Forms, startField, endField string) (time.Time, time.Time) {
+	form.Required(startField, endField)
+
+	if !form.IsDate(startField) || !form.IsDate(endField) {
+		return time.Time{}, time.Time{}
+	}
+
+	startDate, _ := time.Parse(apiDateLayout, form.Get(startField))
+	endDate, _ := time.Parse(apiDateLayout, form.Get(endField))
+
+	if !endDate.After(startDate) {
+		form.Errors.Add(endField, "End date must be after start date")
+	}
+
+	return startDate, endDate
+}
+
+// APIListRooms returns all rooms
+func (this *Repository) APIListRooms(w http.ResponseWriter, r *http.Request) {
+
+	rooms, err := this.DB.AllRooms()
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	resp := make([]roomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		resp = append(resp, newRoomResponse(room))
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, resp)
+}
+
+// APIAvailability returns the available rooms for a date range, or whether one room is available when room_id is given
+func (this *Repository) APIAvailability(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+
+	startDate, endDate := validateDateRange(form, "start", "end")
+
+	if form.Get("room_id") != "" {
+		form.IsInt("room_id")
+	}
+
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, "Invalid query parameters", form.Errors)
+		return
+	}
+
+	resp := availabilityResponse{
+		StartDate: startDate.Format(apiDateLayout),
+		EndDate:   endDate.Format(apiDateLayout),
+	}
+
+	if form.Get("room_id") != "" {
+		roomID, _ := strconv.Atoi(form.Get("room_id"))
+
+		_, err := this.DB.GetRoomByID(roomID)
+		if errors.Is(err, sql.ErrNoRows) {
+			helpers.ErrorJSON(w, http.StatusNotFound, "Room not found", nil)
+			return
+		} else if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		available, err := this.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
+		if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		resp.RoomID = roomID
+		resp.Available = &available
+
+		helpers.WriteJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	rooms, err := this.DB.SearchAvailabilityForAllRooms(startDate, endDate)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	resp.Rooms = make([]roomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		resp.Rooms = append(resp.Rooms, newRoomResponse(room))
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, resp)
+}
+
+// APICreateReservation books a room for a guest
+func (this *Repository) APICreateReservation(w http.ResponseWriter, r *http.Request) {
+
+	var req reservationRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, "Request body must be a valid JSON object", nil)
+		return
+	}
+
+	// run the body through the same validation as the reservation form
+	values := url.Values{}
+	values.Set("first_name", req.FirstName)
+	values.Set("last_name", req.LastName)
+	values.Set("email", req.Email)
+	values.Set("phone", req.Phone)
+	values.Set("start_date", req.StartDate)
+	values.Set("end_date", req.EndDate)
+	values.Set("room_id", strconv.Itoa(req.RoomID))
+
+	form := forms.New(values)
+
+	form.Required("first_name", "last_name", "email")
+	form.MinLength("first_name", 3)
+	form.IsEmail("email")
+	form.IsInt("room_id")
+
+	startDate, endDate := validateDateRange(form, "start_date", "end_date")
+
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, "Invalid reservation", form.Errors)
+		return
+	}
+
+	room, err := this.DB.GetRoomByID(req.RoomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		form.Errors.Add("room_id", "Room does not exist")
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, "Invalid reservation", form.Errors)
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	available, err := this.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, req.RoomID)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	if !available {
+		helpers.ErrorJSON(w, http.StatusConflict, "Room is not available for these dates", nil)
+		return
+	}
+
+	reservation := models.Reservation{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     req.Email,
+		Phone:     req.Phone,
+		StartDate: startDate,
+		EndDate:   endDate,
+		RoomID:    req.RoomID,
+		Room:      room,
+	}
+
+	reservation.ID, err = this.DB.InsertReservation(reservation)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	restriction := models.RoomRestriction{
+		StartDate:     startDate,
+		EndDate:       endDate,
+		RoomID:        req.RoomID,
+		ReservationID: reservation.ID,
+		RestrictionID: 1,
+	}
+
+	err = this.DB.InsertRoomRestriction(restriction)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	this.App.Metrics.BookingCreated("api")
+	this.recordAudit(r, audit.ActionCreate, audit.EntityReservation, reservation.ID, nil, audit.Reservation(reservation))
+	this.fireWebhook(webhooks.ReservationCreated, newReservationResponse(reservation))
+
+	htmlMessage := fmt.Sprintf(
+		`
+		<strong>Reservation Confirmation</strong> <br>
+		Dear %s: <br>
+		This is confirm your reservation from %s to %s.
+		`,
+		reservation.FirstName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"))
+
+	this.App.MailChan <- models.MailData{
+		To:       reservation.Email,
+		From:     "linshotel@hotel.com",
+		Subject:  "Reservation Confirmation",
+		Content:  htmlMessage,
+		Template: "basic.html",
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/reservations/%d", reservation.ID))
+	helpers.WriteJSON(w, http.StatusCreated, newReservationResponse(reservation))
+}
+
+// apiReservationFromURL loads the reservation named by the {id} URL parameter, writing an error response if it can't
+func (this *Repository) apiReservationFromURL(w http.ResponseWriter, r *http.Request) (models.Reservation, bool) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Reservation not found", nil)
+		return models.Reservation{}, false
+	}
+
+	res, err := this.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Reservation not found", nil)
+		return res, false
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return res, false
+	}
+
+	return res, true
+}
+
+// APIGetReservation returns one reservation
+func (this *Repository) APIGetReservation(w http.ResponseWriter, r *http.Request) {
+
+	res, ok := this.apiReservationFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, newReservationResponse(res))
+}
+
+// APICancelReservation cancels a reservation and frees its dates
+func (this *Repository) APICancelReservation(w http.ResponseWriter, r *http.Request) {
+
+	res, ok := this.apiReservationFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	err := this.DB.DeleteReservation(res.ID)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	this.recordAudit(r, audit.ActionDelete, audit.EntityReservation, res.ID, audit.Reservation(res), nil)
+	this.fireWebhook(webhooks.ReservationCancelled, newReservationResponse(res))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APINotFound is the JSON response for unknown API routes
+func (this *Repository) APINotFound(w http.ResponseWriter, r *http.Request) {
+	helpers.ErrorJSON(w, http.StatusNotFound, "Resource not found", nil)
+}
+
+// APIMethodNotAllowed is the JSON response for API routes called with the wrong method
+func (this *Repository) APIMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	helpers.ErrorJSON(w, http.StatusMethodNotAllowed, "Method not allowed", nil)
+}
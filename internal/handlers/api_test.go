@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var apiTests = []struct {
+	name               string
+	url                string
+	method             string
+	body               string
+	expectedStatusCode int
+}{
+	{"rooms", "/api/v1/rooms", "GET", "", http.StatusOK},
+	{"all rooms availability", "/api/v1/availability?start=2050-01-01&end=2050-01-02", "GET", "", http.StatusOK},
+	{"room availability", "/api/v1/availability?start=2050-01-01&end=2050-01-02&room_id=2", "GET", "", http.StatusOK},
+	{"availability bad date", "/api/v1/availability?start=2050-01-01&end=invalid", "GET", "", http.StatusUnprocessableEntity},
+	{"availability end before start", "/api/v1/availability?start=2050-01-02&end=2050-01-01", "GET", "", http.StatusUnprocessableEntity},
+	{"availability unknown room", "/api/v1/availability?start=2050-01-01&end=2050-01-02&room_id=404", "GET", "", http.StatusNotFound},
+	{"availability db error", "/api/v1/availability?start=2050-01-01&end=2050-01-02&room_id=1000", "GET", "", http.StatusInternalServerError},
+	{"create reservation", "/api/v1/reservations", "POST",
+		`{"first_name":"John","last_name":"Smith","email":"john@here.com","start_date":"2050-01-01","end_date":"2050-01-02","room_id":2}`,
+		http.StatusCreated},
+	{"create reservation invalid json", "/api/v1/reservations", "POST", `{"first_name":`, http.StatusBadRequest},
+	{"create reservation unknown field", "/api/v1/reservations", "POST", `{"nickname":"J"}`, http.StatusBadRequest},
+	{"create reservation invalid data", "/api/v1/reservations", "POST",
+		`{"first_name":"J","last_name":"Smith","email":"john","start_date":"2050-01-01","end_date":"2050-01-02","room_id":2}`,
+		http.StatusUnprocessableEntity},
+	{"create reservation unknown room", "/api/v1/reservations", "POST",
+		`{"first_name":"John","last_name":"Smith","email":"john@here.com","start_date":"2050-01-01","end_date":"2050-01-02","room_id":404}`,
+		http.StatusUnprocessableEntity},
+	{"create reservation unavailable", "/api/v1/reservations", "POST",
+		`{"first_name":"John","last_name":"Smith","email":"john@here.com","start_date":"2050-01-01","end_date":"2050-01-02","room_id":1002}`,
+		http.StatusConflict},
+	{"get reservation", "/api/v1/reservations/1", "GET", "", http.StatusOK},
+	{"get missing reservation", "/api/v1/reservations/1000", "GET", "", http.StatusNotFound},
+	{"get reservation db error", "/api/v1/reservations/1001", "GET", "", http.StatusInternalServerError},
+	{"get reservation bad id", "/api/v1/reservations/abc", "GET", "", http.StatusNotFound},
+	{"cancel reservation", "/api/v1/reservations/1", "DELETE", "", http.StatusNoContent},
+	{"cancel missing reservation", "/api/v1/reservations/1000", "DELETE", "", http.StatusNotFound},
+	{"unknown route", "/api/v1/nothing", "GET", "", http.StatusNotFound},
+	{"wrong method", "/api/v1/rooms", "POST", "", http.StatusMethodNotAllowed},
+}
+
+func TestAPI(t *testing.T) {
+	routes := getRoutes()
+
+	for _, e := range apiTests {
+
+		req, _ := http.NewRequest(e.method, e.url, strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+
+		if rr.Code == http.StatusNoContent {
+			continue
+		}
+
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("for %s, expected application/json but got %q", e.name, ct)
+		}
+
+		var body map[string]interface{}
+		var list []interface{}
+		if json.Unmarshal(rr.Body.Bytes(), &body) != nil && json.Unmarshal(rr.Body.Bytes(), &list) != nil {
+			t.Errorf("for %s, failed to parse json: %s", e.name, rr.Body.String())
+		}
+
+		if rr.Code >= 400 {
+			if _, ok := body["error"]; !ok {
+				t.Errorf("for %s, expected an error body but got %s", e.name, rr.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+)
+
+// remoteIP is the address a request came from, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordAudit appends an administrative change by the logged in user, or by the API key the request was made
+// with, to the audit log. A nil before or after stands for an entity that was created or deleted. Failing to
+// record it is logged, the change itself stands.
+func (this *Repository) recordAudit(r *http.Request, action, entity string, id int, before, after audit.Snapshot) {
+
+	entry := models.AuditEntry{
+		UserID:   this.App.Session.GetInt(r.Context(), "user_id"),
+		RemoteIP: remoteIP(r),
+		Action:   action,
+		Entity:   entity,
+		EntityID: id,
+		Changes:  audit.Changes(before, after),
+	}
+
+	if key, ok := helpers.APIKeyFromContext(r.Context()); ok {
+		entry.APIKeyID = key.ID
+	}
+
+	err := this.DB.InsertAuditEntry(entry)
+	if err != nil {
+		this.App.Logger.ErrorContext(r.Context(), "cannot record audit entry", logging.Err(err))
+	}
+}
+
+// auditQueryFromForm reads the audit log filters and page, adding errors to the form for bad values
+func auditQueryFromForm(form *forms.Forms) models.AuditQuery {
+	q := models.AuditQuery{
+		Page:    1,
+		PerPage: 50,
+	}
+
+	if e := form.Get("entity"); e != "" {
+		if contains(audit.Entities, e) {
+			q.Entity = e
+		} else {
+			form.Errors.Add("entity", "Unknown entity")
+		}
+	}
+
+	if a := form.Get("action"); a != "" {
+		if contains(audit.Actions, a) {
+			q.Action = a
+		} else {
+			form.Errors.Add("action", "Unknown action")
+		}
+	}
+
+	if form.Get("entity_id") != "" && form.IsInt("entity_id") {
+		q.EntityID, _ = strconv.Atoi(form.Get("entity_id"))
+	}
+
+	if form.Get("user_id") != "" && form.IsInt("user_id") {
+		q.UserID, _ = strconv.Atoi(form.Get("user_id"))
+	}
+
+	if form.Get("page") != "" && form.IsInt("page") {
+		q.Page, _ = strconv.Atoi(form.Get("page"))
+	}
+
+	return q
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminAuditLog lists administrative changes, newest first
+func (this *Repository) AdminAuditLog(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+	q := auditQueryFromForm(form)
+
+	var entries []models.AuditEntry
+	var total int
+
+	if form.Valid() {
+		var err error
+		entries, total, err = this.DB.AuditEntries(q)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["entries"] = entries
+	data["entities"] = audit.Entities
+	data["actions"] = audit.Actions
+
+	stringMap := make(map[string]string)
+	stringMap["pager_noun"] = "entries"
+
+	pages := q.Pages(total)
+	if q.Page > 1 {
+		stringMap["prev_url"] = listURL(r, map[string]string{"page": strconv.Itoa(q.Page - 1)})
+	}
+	if q.Page < pages {
+		stringMap["next_url"] = listURL(r, map[string]string{"page": strconv.Itoa(q.Page + 1)})
+	}
+
+	intMap := make(map[string]int)
+	intMap["total"] = total
+	intMap["page"] = q.Page
+	intMap["pages"] = pages
+
+	if err := render.Template(w, r, "admin-audit.page.tmpl", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		IntMap:    intMap,
+		Form:      form,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
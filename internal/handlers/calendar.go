@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/icalsync"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+	"github.com/gummy789j/bookings/internal/repository"
+	"github.com/gummy789j/bookings/internal/webhooks"
+)
+
+// Views of the reservations calendar
+const (
+	calendarWeek    = "week"
+	calendarMonth   = "month"
+	calendarQuarter = "quarter"
+)
+
+// calendarSpan is the run of days one page of the calendar shows. End is the day after the last one,
+// Prev and Next are where the pages before and after start.
+type calendarSpan struct {
+	View  string
+	Start time.Time
+	End   time.Time
+	Prev  time.Time
+	Next  time.Time
+}
+
+// Title names the span for the page heading
+func (c calendarSpan) Title() string {
+	last := c.End.AddDate(0, 0, -1)
+
+	switch c.View {
+	case calendarWeek:
+		return c.Start.Format("2 Jan") + " - " + last.Format("2 Jan 2006")
+	case calendarQuarter:
+		return c.Start.Format("January 2006") + " - " + last.Format("January 2006")
+	}
+
+	return c.Start.Format("January 2006")
+}
+
+// calendarSpanFromForm reads the view and date to show, adding errors to the form for bad values.
+// Weeks start on Monday and the other views on the first of the month holding the date. Without a date
+// the y and m of older links are used, then today.
+func calendarSpanFromForm(form *forms.Forms, now time.Time) calendarSpan {
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if form.Get("date") != "" && form.IsDate("date") {
+		date, _ = time.Parse(apiDateLayout, form.Get("date"))
+	} else if form.Get("date") == "" && form.Get("y") != "" {
+		year, errY := strconv.Atoi(form.Get("y"))
+		month, errM := strconv.Atoi(form.Get("m"))
+		if errY == nil && errM == nil && month >= 1 && month <= 12 {
+			date = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+
+	c := calendarSpan{View: form.Get("view")}
+
+	switch c.View {
+	case calendarWeek:
+		c.Start = date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+		c.End = c.Start.AddDate(0, 0, 7)
+		c.Prev = c.Start.AddDate(0, 0, -7)
+	case calendarQuarter:
+		c.Start = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		c.End = c.Start.AddDate(0, 3, 0)
+		c.Prev = c.Start.AddDate(0, -3, 0)
+	case "", calendarMonth:
+		c.View = calendarMonth
+		c.Start = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		c.End = c.Start.AddDate(0, 1, 0)
+		c.Prev = c.Start.AddDate(0, -1, 0)
+	default:
+		form.Errors.Add("view", "Unknown view")
+		return calendarSpanFromForm(forms.New(nil), now)
+	}
+
+	c.Next = c.End
+
+	return c
+}
+
+// AdminReservationsCalendar displays the reservation calendar, the grid itself is filled in from AdminCalendarData
+func (this *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+
+	c := calendarSpanFromForm(forms.New(r.URL.Query()), time.Now())
+
+	stringMap := make(map[string]string)
+	stringMap["view"] = c.View
+	stringMap["title"] = c.Title()
+	stringMap["date"] = c.Start.Format(apiDateLayout)
+	stringMap["prev"] = c.Prev.Format(apiDateLayout)
+	stringMap["next"] = c.Next.Format(apiDateLayout)
+	stringMap["year"] = c.Start.Format("2006")
+	stringMap["month"] = c.Start.Format("01")
+
+	if err := render.Template(w, r, "admin-reservations-calendar.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// calendarResponse is everything on one page of the calendar. Stays run from start up to the day before end,
+// like the nights of a reservation.
+type calendarResponse struct {
+	View  string         `json:"view"`
+	Start string         `json:"start"`
+	End   string         `json:"end"`
+	Days  []string       `json:"days"`
+	Rooms []calendarRoom `json:"rooms"`
+}
+
+// calendarRoom is one row of the calendar
+type calendarRoom struct {
+	ID           int                   `json:"id"`
+	Name         string                `json:"name"`
+	Reservations []calendarReservation `json:"reservations"`
+	Blocks       []calendarStay        `json:"blocks"`
+	External     []calendarStay        `json:"external"`
+}
+
+// calendarReservation is a guest's stay in a room
+type calendarReservation struct {
+	ID        int    `json:"id"`
+	Guest     string `json:"guest"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Processed bool   `json:"processed"`
+}
+
+// calendarStay is a block or external booking of a room
+type calendarStay struct {
+	ID    int    `json:"id"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// AdminCalendarData returns the rooms, reservations and blocks of a page of the calendar as JSON
+func (this *Repository) AdminCalendarData(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+
+	c := calendarSpanFromForm(form, time.Now())
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusBadRequest, "Invalid calendar view", form.Errors)
+		return
+	}
+
+	rooms, err := this.DB.AllRooms()
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	restrictions, err := this.DB.GetRestrictionsByDate(c.Start, c.End)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	resp := calendarResponse{
+		View:  c.View,
+		Start: c.Start.Format(apiDateLayout),
+		End:   c.End.Format(apiDateLayout),
+		Days:  []string{},
+		Rooms: []calendarRoom{},
+	}
+
+	for d := c.Start; d.Before(c.End); d = d.AddDate(0, 0, 1) {
+		resp.Days = append(resp.Days, d.Format(apiDateLayout))
+	}
+
+	index := make(map[int]int)
+	for i, room := range rooms {
+		index[room.ID] = i
+		resp.Rooms = append(resp.Rooms, calendarRoom{
+			ID:           room.ID,
+			Name:         room.RoomName,
+			Reservations: []calendarReservation{},
+			Blocks:       []calendarStay{},
+			External:     []calendarStay{},
+		})
+	}
+
+	for _, rr := range restrictions {
+		i, ok := index[rr.RoomID]
+		if !ok {
+			continue
+		}
+
+		stay := calendarStay{
+			ID:    rr.ID,
+			Start: rr.StartDate.Format(apiDateLayout),
+			End:   rr.EndDate.Format(apiDateLayout),
+		}
+
+		switch {
+		case rr.ReservationID > 0:
+			resp.Rooms[i].Reservations = append(resp.Rooms[i].Reservations, calendarReservation{
+				ID:        rr.ReservationID,
+				Guest:     rr.Reservation.FirstName + " " + rr.Reservation.LastName,
+				Start:     stay.Start,
+				End:       stay.End,
+				Processed: rr.Reservation.Processed == 1,
+			})
+		case rr.RestrictionID == icalsync.ExternalBooking:
+			resp.Rooms[i].External = append(resp.Rooms[i].External, stay)
+		default:
+			resp.Rooms[i].Blocks = append(resp.Rooms[i].Blocks, stay)
+		}
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, resp)
+}
+
+// AdminCalendarBlock blocks a room for the night of a date, if it is free
+func (this *Repository) AdminCalendarBlock(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, "Can't parse form", nil)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "date")
+	if form.Has("room_id") {
+		form.IsInt("room_id")
+	}
+	if form.Has("date") {
+		form.IsDate("date")
+	}
+
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, "Invalid block", form.Errors)
+		return
+	}
+
+	roomID, _ := strconv.Atoi(form.Get("room_id"))
+	date, _ := time.Parse(apiDateLayout, form.Get("date"))
+
+	available, err := this.DB.SearchAvailabilityByDatesByRoomID(date, date.AddDate(0, 0, 1), roomID)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	if !available {
+		helpers.ErrorJSON(w, http.StatusConflict, "The room is already taken that night", nil)
+		return
+	}
+
+	err = this.DB.InsertBlockForRoom(roomID, date)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	block := models.RoomRestriction{RoomID: roomID, StartDate: date, EndDate: date.AddDate(0, 0, 1)}
+	this.recordAudit(r, audit.ActionCreate, audit.EntityBlock, 0, nil, audit.Block(block))
+
+	payload := blockPayload{RoomID: roomID, Date: date.Format(apiDateLayout)}
+	this.fireWebhook(webhooks.BlockCreated, payload)
+
+	helpers.WriteJSON(w, http.StatusCreated, payload)
+}
+
+// AdminCalendarUnblock removes an owner block
+func (this *Repository) AdminCalendarUnblock(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Block not found", nil)
+		return
+	}
+
+	// reservations and external bookings aren't blocks, and what is recorded is the block as it was stored
+	block, err := this.DB.GetBlockByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Block not found", nil)
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	err = this.DB.DeleteBlockByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Block not found", nil)
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	this.recordAudit(r, audit.ActionDelete, audit.EntityBlock, id, audit.Block(block), nil)
+
+	payload := blockPayload{ID: id, RoomID: block.RoomID, Date: block.StartDate.Format(apiDateLayout)}
+	this.fireWebhook(webhooks.BlockDeleted, payload)
+
+	helpers.WriteJSON(w, http.StatusOK, payload)
+}
+
+// AdminCalendarMoveReservation moves a reservation to another room or start date, keeping its length,
+// as long as nothing else is booked there
+func (this *Repository) AdminCalendarMoveReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Reservation not found", nil)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, "Can't parse form", nil)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "start_date")
+	if form.Has("room_id") {
+		form.IsInt("room_id")
+	}
+	if form.Has("start_date") {
+		form.IsDate("start_date")
+	}
+
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, "Invalid move", form.Errors)
+		return
+	}
+
+	res, err := this.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, "Reservation not found", nil)
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	roomID, _ := strconv.Atoi(form.Get("room_id"))
+
+	room, err := this.DB.GetRoomByID(roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, "Invalid move", map[string][]string{"room_id": {"No such room"}})
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	nights := int(res.EndDate.Sub(res.StartDate).Hours() / 24)
+	if nights < 1 {
+		nights = 1
+	}
+
+	start, _ := time.Parse(apiDateLayout, form.Get("start_date"))
+	end := start.AddDate(0, 0, nights)
+
+	// the room is checked inside the move, so two drags onto the same nights can't both succeed
+	err = this.DB.MoveReservation(res.ID, roomID, start, end)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		helpers.ErrorJSON(w, http.StatusConflict, room.RoomName+" is not free from "+start.Format(apiDateLayout)+" to "+end.Format(apiDateLayout), nil)
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	before := audit.Reservation(res)
+
+	res.RoomID = roomID
+	res.Room = room
+	res.StartDate = start
+	res.EndDate = end
+
+	this.recordAudit(r, audit.ActionMove, audit.EntityReservation, res.ID, before, audit.Reservation(res))
+
+	this.fireWebhook(webhooks.ReservationUpdated, newReservationResponse(res))
+
+	helpers.WriteJSON(w, http.StatusOK, newReservationResponse(res))
+}
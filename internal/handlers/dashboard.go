@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+)
+
+// maxDashboardDays bounds the range of dates the dashboard reports on
+const maxDashboardDays = 3 * 366
+
+// dashboardRangeFromForm reads the dashboard's from and to dates, adding errors to the form for bad values.
+// Without them the range is the current month and the five before it.
+func dashboardRangeFromForm(form *forms.Forms, now time.Time) (from, to time.Time) {
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	from = firstOfMonth.AddDate(0, -5, 0)
+	to = firstOfMonth.AddDate(0, 1, -1)
+
+	if form.Get("from") != "" && form.IsDate("from") {
+		from, _ = time.Parse(apiDateLayout, form.Get("from"))
+	}
+
+	if form.Get("to") != "" && form.IsDate("to") {
+		to, _ = time.Parse(apiDateLayout, form.Get("to"))
+	}
+
+	if to.Before(from) {
+		form.Errors.Add("to", "Must not be before the from date")
+	} else if to.Sub(from) > maxDashboardDays*24*time.Hour {
+		form.Errors.Add("to", fmt.Sprintf("The range can be at most %d days", maxDashboardDays))
+	}
+
+	return from, to
+}
+
+// AdminDashBoard shows the headline figures and charts for a range of dates
+func (this *Repository) AdminDashBoard(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+
+	from, to := dashboardRangeFromForm(form, time.Now())
+	if !form.Valid() {
+		from, to = dashboardRangeFromForm(forms.New(nil), time.Now())
+	}
+
+	summary, err := this.DB.DashboardSummary(from, to)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["summary"] = summary
+
+	stringMap := make(map[string]string)
+	stringMap["from"] = from.Format(apiDateLayout)
+	stringMap["to"] = to.Format(apiDateLayout)
+	stringMap["occupancy"] = fmt.Sprintf("%.1f%%", percent(summary.Occupancy()))
+	stringMap["average_stay"] = fmt.Sprintf("%.1f", summary.AverageStay)
+	stringMap["average_lead_time"] = fmt.Sprintf("%.0f", summary.AverageLeadTime)
+
+	if err := render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		Form:      form,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// dashboardSummaryResponse is the JSON form of the dashboard's headline figures
+type dashboardSummaryResponse struct {
+	From            string  `json:"from"`
+	To              string  `json:"to"`
+	Reservations    int     `json:"reservations"`
+	NightsSold      int     `json:"nights_sold"`
+	RoomNights      int     `json:"room_nights"`
+	Occupancy       float64 `json:"occupancy"`
+	AverageStay     float64 `json:"average_stay"`
+	AverageLeadTime float64 `json:"average_lead_time"`
+	ArrivalsToday   int     `json:"arrivals_today"`
+	DeparturesToday int     `json:"departures_today"`
+	NewReservations int     `json:"new_reservations"`
+}
+
+// AdminDashboardSummary returns the dashboard's headline figures as JSON
+func (this *Repository) AdminDashboardSummary(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+
+	from, to := dashboardRangeFromForm(form, time.Now())
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusBadRequest, "Invalid date range", form.Errors)
+		return
+	}
+
+	s, err := this.DB.DashboardSummary(from, to)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, dashboardSummaryResponse{
+		From:            from.Format(apiDateLayout),
+		To:              to.Format(apiDateLayout),
+		Reservations:    s.Reservations,
+		NightsSold:      s.NightsSold,
+		RoomNights:      s.RoomNights,
+		Occupancy:       round1(percent(s.Occupancy())),
+		AverageStay:     round1(s.AverageStay),
+		AverageLeadTime: round1(s.AverageLeadTime),
+		ArrivalsToday:   s.ArrivalsToday,
+		DeparturesToday: s.DeparturesToday,
+		NewReservations: s.NewReservations,
+	})
+}
+
+// occupancyResponse holds one series per room, each with a value per month, ready for a chart
+type occupancyResponse struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Months     []string        `json:"months"`
+	NightsSold []int           `json:"nights_sold"`
+	Rooms      []roomOccupancy `json:"rooms"`
+}
+
+// roomOccupancy is one room's nights sold and occupancy in percent, per month
+type roomOccupancy struct {
+	RoomID     int       `json:"room_id"`
+	RoomName   string    `json:"room_name"`
+	NightsSold []int     `json:"nights_sold"`
+	Occupancy  []float64 `json:"occupancy"`
+}
+
+// AdminDashboardOccupancy returns the occupancy of every room per month as JSON
+func (this *Repository) AdminDashboardOccupancy(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+
+	from, to := dashboardRangeFromForm(form, time.Now())
+	if !form.Valid() {
+		helpers.ErrorJSON(w, http.StatusBadRequest, "Invalid date range", form.Errors)
+		return
+	}
+
+	rows, err := this.DB.RoomOccupancy(from, to)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	resp := occupancyResponse{
+		From:       from.Format(apiDateLayout),
+		To:         to.Format(apiDateLayout),
+		Months:     []string{},
+		NightsSold: []int{},
+		Rooms:      []roomOccupancy{},
+	}
+
+	months := make(map[string]int)
+	rooms := make(map[int]int)
+
+	for _, o := range rows {
+		label := o.Month.Format("2006-01")
+		if _, ok := months[label]; !ok {
+			months[label] = len(resp.Months)
+			resp.Months = append(resp.Months, label)
+			resp.NightsSold = append(resp.NightsSold, 0)
+		}
+		if _, ok := rooms[o.RoomID]; !ok {
+			rooms[o.RoomID] = len(resp.Rooms)
+			resp.Rooms = append(resp.Rooms, roomOccupancy{RoomID: o.RoomID, RoomName: o.RoomName})
+		}
+	}
+
+	for i := range resp.Rooms {
+		resp.Rooms[i].NightsSold = make([]int, len(resp.Months))
+		resp.Rooms[i].Occupancy = make([]float64, len(resp.Months))
+	}
+
+	for _, o := range rows {
+		m, room := months[o.Month.Format("2006-01")], &resp.Rooms[rooms[o.RoomID]]
+		room.NightsSold[m] = o.NightsSold
+		room.Occupancy[m] = round1(percent(o.Occupancy()))
+		resp.NightsSold[m] += o.NightsSold
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, resp)
+}
+
+// percent turns a share from 0 to 1 into a percentage
+func percent(share float64) float64 {
+	return share * 100
+}
+
+// round1 rounds to one decimal place
+func round1(x float64) float64 {
+	return math.Round(x*10) / 10
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gummy789j/bookings/internal/helpers"
+)
+
+// NotFound shows the not found page for unknown routes
+func (this *Repository) NotFound(w http.ResponseWriter, r *http.Request) {
+	helpers.ClientError(w, http.StatusNotFound)
+}
+
+// MethodNotAllowed answers routes called with the wrong method
+func (this *Repository) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	helpers.ClientError(w, http.StatusMethodNotAllowed)
+}
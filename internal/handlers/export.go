@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/export"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+)
+
+// reservationFilterFromForm reads the list screen filters, adding errors to the form for bad values
+func reservationFilterFromForm(form *forms.Forms) models.ReservationFilter {
+	var f models.ReservationFilter
+
+	f.Search = strings.TrimSpace(form.Get("q"))
+
+	if form.Get("from") != "" && form.IsDate("from") {
+		f.From, _ = time.Parse(apiDateLayout, form.Get("from"))
+	}
+
+	if form.Get("to") != "" && form.IsDate("to") {
+		f.To, _ = time.Parse(apiDateLayout, form.Get("to"))
+	}
+
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		form.Errors.Add("to", "Must not be before the from date")
+	}
+
+	if form.Get("room_id") != "" && form.IsInt("room_id") {
+		f.RoomID, _ = strconv.Atoi(form.Get("room_id"))
+	}
+
+	switch form.Get("status") {
+	case "", models.StatusNew, models.StatusProcessed:
+		f.Status = form.Get("status")
+	default:
+		form.Errors.Add("status", "Unknown status")
+	}
+
+	return f
+}
+
+// defaultPerPage and maxPerPage bound the size of a reservations list page
+const (
+	defaultPerPage = 25
+	maxPerPage     = 100
+)
+
+// reservationQueryFromForm reads the list screen filters, sort and page, adding errors to the form for bad values
+func reservationQueryFromForm(form *forms.Forms) models.ReservationQuery {
+	q := models.ReservationQuery{
+		ReservationFilter: reservationFilterFromForm(form),
+		Sort:              "-arrival",
+		Page:              1,
+		PerPage:           defaultPerPage,
+	}
+
+	if sort := form.Get("sort"); sort != "" {
+		known := false
+		for _, key := range models.ReservationSorts {
+			if strings.TrimPrefix(sort, "-") == key {
+				known = true
+			}
+		}
+		if known {
+			q.Sort = sort
+		} else {
+			form.Errors.Add("sort", "Unknown sort")
+		}
+	}
+
+	if form.Get("page") != "" && form.IsInt("page") {
+		q.Page, _ = strconv.Atoi(form.Get("page"))
+	}
+
+	if form.Get("per_page") != "" && form.IsInt("per_page") {
+		q.PerPage, _ = strconv.Atoi(form.Get("per_page"))
+		if q.PerPage > maxPerPage {
+			q.PerPage = maxPerPage
+		}
+	}
+
+	return q
+}
+
+// listURL is the current list URL with some query parameters replaced
+func listURL(r *http.Request, set map[string]string) string {
+	values := r.URL.Query()
+	for k, v := range set {
+		values.Set(k, v)
+	}
+	return r.URL.Path + "?" + values.Encode()
+}
+
+// reservationExportColumns is the header row of a reservations export
+var reservationExportColumns = []string{
+	"ID", "First Name", "Last Name", "Email", "Phone", "Room", "Arrival", "Departure", "Nights", "Status", "Booked",
+}
+
+func reservationExportRow(res models.Reservation) []string {
+	status := models.StatusNew
+	if res.Processed == 1 {
+		status = models.StatusProcessed
+	}
+
+	return []string{
+		strconv.Itoa(res.ID),
+		res.FirstName,
+		res.LastName,
+		res.Email,
+		res.Phone,
+		res.Room.RoomName,
+		res.StartDate.Format(apiDateLayout),
+		res.EndDate.Format(apiDateLayout),
+		strconv.Itoa(int(res.EndDate.Sub(res.StartDate).Hours() / 24)),
+		status,
+		res.CreatedAt.Format("2006-01-02 15:04"),
+	}
+}
+
+// AdminExportReservations downloads the reservations matching the list screen filters as CSV or XLSX
+func (this *Repository) AdminExportReservations(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(r.URL.Query())
+	filter := reservationFilterFromForm(form)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		form.Errors.Add("format", "Must be csv or xlsx")
+	}
+
+	if !form.Valid() {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var out export.RowWriter
+
+	// headers are only sent once the first row is ready, so a failed query can still get an error page
+	start := func() error {
+		if out != nil {
+			return nil
+		}
+
+		filename := fmt.Sprintf("reservations-%s.%s", time.Now().Format("20060102"), format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		var err error
+		if format == "xlsx" {
+			w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+			out, err = export.NewXLSX(w, "Reservations")
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			out, err = export.NewCSV(w)
+		}
+		if err != nil {
+			return err
+		}
+
+		return out.Write(reservationExportColumns)
+	}
+
+	err := this.DB.EachReservation(filter, func(res models.Reservation) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return out.Write(reservationExportRow(res))
+	})
+
+	if err == nil {
+		err = start()
+	}
+
+	if err != nil {
+		if out == nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		// the download has begun, all that can be done is stop it short
+		this.App.Logger.ErrorContext(r.Context(), "reservations export failed", logging.Err(err))
+		return
+	}
+
+	if err := out.Close(); err != nil {
+		this.App.Logger.ErrorContext(r.Context(), "reservations export failed", logging.Err(err))
+	}
+}
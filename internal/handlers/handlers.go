@@ -1,26 +1,32 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 
-	"log"
+	"log/slog"
 
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/audit"
 	"github.com/gummy789j/bookings/internal/config"
 	"github.com/gummy789j/bookings/internal/driver"
 	"github.com/gummy789j/bookings/internal/forms"
 	"github.com/gummy789j/bookings/internal/helpers"
 
+	"github.com/gummy789j/bookings/internal/logging"
 	"github.com/gummy789j/bookings/internal/models"
 	"github.com/gummy789j/bookings/internal/render"
 	"github.com/gummy789j/bookings/internal/repository"
 	"github.com/gummy789j/bookings/internal/repository/dbrepo"
+	"github.com/gummy789j/bookings/internal/webhooks"
 )
 
 var Repo *Repository
@@ -55,7 +61,9 @@ func (this *Repository) Home(w http.ResponseWriter, r *http.Request) {
 
 	this.App.Session.Put(r.Context(), "remote_ip", remoteIP)
 
-	render.Template(w, r, "home.page.tmpl", &models.TemplateData{})
+	if err := render.Template(w, r, "home.page.tmpl", &models.TemplateData{}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // About
@@ -69,15 +77,19 @@ func (this *Repository) About(w http.ResponseWriter, r *http.Request) {
 
 	stringMap["remote_ip"] = remoteIP
 
-	render.Template(w, r, "about.page.tmpl", &models.TemplateData{
+	if err := render.Template(w, r, "about.page.tmpl", &models.TemplateData{
 		StringMap: stringMap,
-	})
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // search-availability
 func (this *Repository) Availability(w http.ResponseWriter, r *http.Request) {
 
-	render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{})
+	if err := render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // Post search-availability
@@ -118,10 +130,13 @@ func (this *Repository) PostAvailability(w http.ResponseWriter, r *http.Request)
 
 	data["rooms"] = rooms
 
-	render.Template(w, r, "choose-room.page.tmpl", &models.TemplateData{
+	if err := render.Template(w, r, "choose-room.page.tmpl", &models.TemplateData{
 		Form: forms.New(nil),
 		Data: data,
-	})
+	}); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
 	res := models.Reservation{
 		StartDate: startDate,
@@ -236,11 +251,13 @@ func (this *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 
 	data["reservation"] = res
 
-	render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
+	if err := render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
 		Form:      forms.New(nil),
 		Data:      data,
 		StringMap: stringMap,
-	})
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
 
 }
 
@@ -320,11 +337,13 @@ func (this *Repository) PostReservation(w http.ResponseWriter, r *http.Request)
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
 		//http.Error(w, "my own error message", http.StatusSeeOther)
-		render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
+		if err := render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
 			Form:      form,
 			Data:      data,
 			StringMap: stringMap,
-		})
+		}); err != nil {
+			helpers.ServerError(w, err)
+		}
 		return
 	}
 
@@ -350,6 +369,10 @@ func (this *Repository) PostReservation(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	reservation.ID = newReservationID
+	this.App.Metrics.BookingCreated("web")
+	this.fireWebhook(webhooks.ReservationCreated, newReservationResponse(reservation))
+
 	// send notification - first to guest)
 	htmlMessage := fmt.Sprintf(
 		`
@@ -377,19 +400,25 @@ func (this *Repository) PostReservation(w http.ResponseWriter, r *http.Request)
 // Generals
 func (this *Repository) Generals(w http.ResponseWriter, r *http.Request) {
 
-	render.Template(w, r, "generals.page.tmpl", &models.TemplateData{})
+	if err := render.Template(w, r, "generals.page.tmpl", &models.TemplateData{}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // Majors
 func (this *Repository) Majors(w http.ResponseWriter, r *http.Request) {
 
-	render.Template(w, r, "majors.page.tmpl", &models.TemplateData{})
+	if err := render.Template(w, r, "majors.page.tmpl", &models.TemplateData{}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // Contact
 func (this *Repository) Contact(w http.ResponseWriter, r *http.Request) {
 
-	render.Template(w, r, "contact.page.tmpl", &models.TemplateData{})
+	if err := render.Template(w, r, "contact.page.tmpl", &models.TemplateData{}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // ReservationSummary
@@ -398,7 +427,7 @@ func (this *Repository) ReservationSummary(w http.ResponseWriter, r *http.Reques
 	// it need to assert to models.Reservation type
 	reservation, ok := this.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		this.App.ErrorLog.Println("Can't get error from session")
+		this.App.Logger.ErrorContext(r.Context(), "can't get reservation from session")
 		this.App.Session.Put(r.Context(), "error", "Can't get reservation from session")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
@@ -413,10 +442,12 @@ func (this *Repository) ReservationSummary(w http.ResponseWriter, r *http.Reques
 	stringMap["start_date"] = reservation.StartDate.Format("2006-01-02")
 	stringMap["end_date"] = reservation.EndDate.Format("2006-01-02")
 
-	render.Template(w, r, "reservation-summary.page.tmpl", &models.TemplateData{
+	if err := render.Template(w, r, "reservation-summary.page.tmpl", &models.TemplateData{
 		Data:      data,
 		StringMap: stringMap,
-	})
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 // ChooseRoom
@@ -473,9 +504,11 @@ func (this *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 
 // ShowLogin
 func (this *Repository) ShowLogin(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "login.page.tmpl", &models.TemplateData{
+	if err := render.Template(w, r, "login.page.tmpl", &models.TemplateData{
 		Form: forms.New(nil),
-	})
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
 }
 
 //PostShowLogin handles logging the user in
@@ -487,7 +520,7 @@ func (this *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 
 	err := r.ParseForm()
 	if err != nil {
-		log.Println(err)
+		this.App.Logger.WarnContext(r.Context(), "cannot parse login form", logging.Err(err))
 	}
 
 	email := r.PostForm.Get("email")
@@ -498,15 +531,18 @@ func (this *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 	form.IsEmail("email")
 	form.Required("email", "password")
 	if !form.Valid() {
-		render.Template(w, r, "login.page.tmpl", &models.TemplateData{
+		if err := render.Template(w, r, "login.page.tmpl", &models.TemplateData{
 			Form: form,
-		})
+		}); err != nil {
+			helpers.ServerError(w, err)
+		}
 		return
 	}
 
 	id, _, err := this.DB.Authenticate(email, password)
 	if err != nil {
-		log.Println(err)
+		this.App.Logger.WarnContext(r.Context(), "login failed", slog.String("email", email), logging.Err(err))
+		this.App.Metrics.LoginFailed()
 		this.App.Session.Put(r.Context(), "error", "Invalid login credentials")
 		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		return
@@ -526,34 +562,41 @@ func (this *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-//AdminDashBoard shows the login screen
-func (this *Repository) AdminDashBoard(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{})
-}
-
 //AdminNewReservations shows all new reservations admin tool
 func (this *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	this.adminReservationList(w, r, "admin-new-reservations.page.tmpl", models.StatusNew)
+}
 
-	reservations, err := this.DB.AllNewReservations()
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
+//AdminNewReservations shows all reservations admin tool
+func (this *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	this.adminReservationList(w, r, "admin-all-reservations.page.tmpl", "")
+}
 
-	data := make(map[string]interface{})
+// adminReservationList renders a page of a reservations list screen with its filters,
+// status is fixed when it isn't empty
+func (this *Repository) adminReservationList(w http.ResponseWriter, r *http.Request, tmpl, status string) {
 
-	data["reservations"] = reservations
+	values := r.URL.Query()
+	if status != "" {
+		values.Set("status", status)
+	}
 
-	render.Template(w, r, "admin-new-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
-	})
+	form := forms.New(values)
+	q := reservationQueryFromForm(form)
 
-}
+	var reservations []models.Reservation
+	var total int
 
-//AdminNewReservations shows all reservations admin tool
-func (this *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	if form.Valid() {
+		var err error
+		reservations, total, err = this.DB.SearchReservations(q)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
 
-	reservations, err := this.DB.AllReservations()
+	rooms, err := this.DB.AllRooms()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
@@ -562,10 +605,46 @@ func (this *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Requ
 	data := make(map[string]interface{})
 
 	data["reservations"] = reservations
+	data["rooms"] = rooms
 
-	render.Template(w, r, "admin-all-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
-	})
+	stringMap := make(map[string]string)
+	stringMap["fixed_status"] = status
+	stringMap["sort"] = q.Sort
+
+	// clicking a column header sorts by it, clicking it again reverses the order
+	for _, key := range models.ReservationSorts {
+		sort := key
+		switch q.Sort {
+		case key:
+			sort = "-" + key
+			stringMap["arrow_"+key] = "▲"
+		case "-" + key:
+			stringMap["arrow_"+key] = "▼"
+		}
+		stringMap["sort_"+key] = listURL(r, map[string]string{"sort": sort, "page": "1"})
+	}
+
+	pages := q.Pages(total)
+	if q.Page > 1 {
+		stringMap["prev_url"] = listURL(r, map[string]string{"page": strconv.Itoa(q.Page - 1)})
+	}
+	if q.Page < pages {
+		stringMap["next_url"] = listURL(r, map[string]string{"page": strconv.Itoa(q.Page + 1)})
+	}
+
+	intMap := make(map[string]int)
+	intMap["total"] = total
+	intMap["page"] = q.Page
+	intMap["pages"] = pages
+
+	if err := render.Template(w, r, tmpl, &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		IntMap:    intMap,
+		Form:      form,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
 
 }
 
@@ -603,15 +682,24 @@ func (this *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	history, _, err := this.DB.AuditEntries(models.AuditQuery{Entity: audit.EntityReservation, EntityID: id, PerPage: 100})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	data := make(map[string]interface{})
 
 	data["reservation"] = res
+	data["history"] = history
 
-	render.Template(w, r, "admin-reservations-show.page.tmpl", &models.TemplateData{
+	if err := render.Template(w, r, "admin-reservations-show.page.tmpl", &models.TemplateData{
 		Data:      data,
 		StringMap: stringMap,
 		Form:      forms.New(nil),
-	})
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
 
 }
 
@@ -638,6 +726,8 @@ func (this *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.
 		return
 	}
 
+	before := audit.Reservation(res)
+
 	res.FirstName = r.PostForm.Get("first_name")
 	res.LastName = r.PostForm.Get("last_name")
 	res.Email = r.PostForm.Get("email")
@@ -649,6 +739,10 @@ func (this *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.
 		return
 	}
 
+	this.recordAudit(r, audit.ActionUpdate, audit.EntityReservation, res.ID, before, audit.Reservation(res))
+
+	this.fireWebhook(webhooks.ReservationUpdated, newReservationResponse(res))
+
 	src := exploded[3]
 
 	// stringMap := make(map[string]string)
@@ -668,192 +762,244 @@ func (this *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.
 
 }
 
-// AdminReservationsCalendar displays the reservation calendar
-func (this *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-
-	now := time.Now()
+// AdminProcessReservation marks a reservation as processed
+func (this *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
 
-	if r.URL.Query().Get("y") != "" {
-		year, _ := strconv.Atoi(r.URL.Query().Get("y"))
-		month, _ := strconv.Atoi(r.URL.Query().Get("m"))
-		now = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	res, ok := this.adminReservationAction(w, r)
+	if !ok {
+		return
 	}
 
-	data := make(map[string]interface{})
-	data["now"] = now
+	back := adminReservationsBack(r)
 
-	next := now.AddDate(0, 1, 0)
-	last := now.AddDate(0, -1, 0)
+	err := this.DB.UpdateProcessedForReservation(res.ID, 1)
+	if err != nil {
+		this.App.Logger.ErrorContext(r.Context(), "cannot mark reservation as processed", slog.Int("reservation_id", res.ID), logging.Err(err))
+		this.App.Session.Put(r.Context(), "error", "Can't mark the reservation as processed, please try again")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
 
-	nextMonth := next.Format("01")
-	nextMonthYear := next.Format("2006")
+	after := res
+	after.Processed = 1
+	this.recordAudit(r, audit.ActionProcess, audit.EntityReservation, res.ID, audit.Reservation(res), audit.Reservation(after))
 
-	lastMonth := last.Format("01")
-	lastMonthYear := last.Format("2006")
+	this.fireWebhook(webhooks.ReservationProcessed, newReservationResponse(after))
 
-	stringMap := make(map[string]string)
-	stringMap["this_month"] = now.Format("01")
-	stringMap["this_month_year"] = now.Format("2006")
-	stringMap["next_month"] = nextMonth
-	stringMap["next_month_year"] = nextMonthYear
-	stringMap["last_month"] = lastMonth
-	stringMap["last_month_year"] = lastMonthYear
-
-	// get the first and last days of the month
-	currnetYear, currentMonth, _ := now.Date()
-	currentLocation := now.Location()
-	firstDayOfMonth := time.Date(currnetYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
-	lastDayOfMonth := firstDayOfMonth.AddDate(0, 1, -1)
+	this.App.Session.Put(r.Context(), "flash", "Reservation marked as processed")
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
 
-	intMap := make(map[string]int)
+// AdminDeleteReservation moves a reservation to the trash
+func (this *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
 
-	// so we know amount of days in this month
+	res, ok := this.adminReservationAction(w, r)
+	if !ok {
+		return
+	}
 
-	intMap["days_in_month"] = lastDayOfMonth.Day()
+	back := adminReservationsBack(r)
 
-	rooms, err := this.DB.AllRooms()
+	err := this.DB.DeleteReservation(res.ID)
 	if err != nil {
-		helpers.ServerError(w, err)
+		this.App.Logger.ErrorContext(r.Context(), "cannot delete reservation", slog.Int("reservation_id", res.ID), logging.Err(err))
+		this.App.Session.Put(r.Context(), "error", "Can't delete the reservation, please try again")
+		http.Redirect(w, r, back, http.StatusSeeOther)
 		return
 	}
 
-	data["rooms"] = rooms
+	this.recordAudit(r, audit.ActionDelete, audit.EntityReservation, res.ID, audit.Reservation(res), nil)
+	this.fireWebhook(webhooks.ReservationCancelled, newReservationResponse(res))
 
-	for _, x := range rooms {
-		// create maps
-		reservationMap := make(map[string]int)
-		blockMap := make(map[string]int)
+	this.App.Session.Put(r.Context(), "flash", "Reservation moved to the trash")
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
 
-		for d := firstDayOfMonth; d.After(lastDayOfMonth) == false; d = d.AddDate(0, 0, 1) {
-			reservationMap[d.Format("2006-01-2")] = 0
-			blockMap[d.Format("2006-01-2")] = 0
-		}
-		// get all the restrictions for the current room
-		rr, err := this.DB.GetRestrictionsForRoomByDate(x.ID, firstDayOfMonth, lastDayOfMonth)
-		if err != nil {
-			helpers.ServerError(w, err)
-			return
-		}
-		for _, y := range rr {
-			if y.ReservationID > 0 {
-				// It's a reservation
-				for d := y.StartDate; d.After(y.EndDate) == false; d = d.AddDate(0, 0, 1) {
-					reservationMap[d.Format("2006-01-2")] = y.ReservationID
-				}
-			} else {
-				// It's a block
-				blockMap[y.StartDate.Format("2006-01-2")] = y.ID
-			}
-		}
-		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
-		//log.Println(reservationMap)
-		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
-		//log.Println(blockMap)
+// adminReservationAction parses the form of a process or delete and loads the reservation in the URL,
+// writing a 404 when there is none. It reports whether the action can go ahead.
+func (this *Repository) adminReservationAction(w http.ResponseWriter, r *http.Request) (models.Reservation, bool) {
 
-		this.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
+	var res models.Reservation
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return res, false
 	}
 
-	render.Template(w, r, "admin-reservations-calendar.page.tmpl", &models.TemplateData{
-		StringMap: stringMap,
-		Data:      data,
-		IntMap:    intMap,
-	})
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return res, false
+	}
 
+	res, err = this.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return res, false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return res, false
+	}
+
+	return res, true
 }
 
-// AdminPostReservationsCalendar handles the psot reservation calendar
-func (this *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
+// adminReservationsBack is where a process or delete returns to, the calendar it came from or else the list
+func adminReservationsBack(r *http.Request) string {
+
+	year := r.PostForm.Get("year")
+	month := r.PostForm.Get("month")
+
+	if year == "" {
+		return fmt.Sprintf("/admin/reservations-%s", chi.URLParam(r, "src"))
+	}
+
+	return fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", url.QueryEscape(year), url.QueryEscape(month))
+}
+
+// AdminAPIKeys lists the API keys issued for integrations
+func (this *Repository) AdminAPIKeys(w http.ResponseWriter, r *http.Request) {
+
+	keys, err := this.DB.AllAPIKeys()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
-	year, _ := strconv.Atoi(r.Form.Get("y"))
-	month, _ := strconv.Atoi(r.Form.Get("m"))
 
-	rooms, err := this.DB.AllRooms()
+	data := make(map[string]interface{})
+	data["keys"] = keys
+
+	// a new key is only ever shown once, right after it was created
+	stringMap := make(map[string]string)
+	stringMap["new_key"] = this.App.Session.PopString(r.Context(), "new_api_key")
+
+	if err := render.Template(w, r, "admin-api-keys.page.tmpl", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		Form:      forms.New(nil),
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminPostAPIKey issues a new API key
+func (this *Repository) AdminPostAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	// a key acts for the user who created it, so there has to be one
+	userID := this.App.Session.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
 	form := forms.New(r.PostForm)
+	form.Required("name", "scope")
 
-	for _, x := range rooms {
-
-		curMap := this.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
+	scope := form.Get("scope")
+	if scope != "read" && scope != "write" {
+		form.Errors.Add("scope", "Scope must be read or write")
+	}
 
-		for date, _ := range curMap {
+	if !form.Valid() {
+		keys, err := this.DB.AllAPIKeys()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
 
-			if rr_id, ok := curMap[date]; ok {
+		data := make(map[string]interface{})
+		data["keys"] = keys
 
-				if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, date)) {
-					// delete the restriction by id
-					err := this.DB.DeleteBlockByID(rr_id)
-					if err != nil {
-						helpers.ServerError(w, err)
-						return
-					}
-				}
-			}
+		if err := render.Template(w, r, "admin-api-keys.page.tmpl", &models.TemplateData{
+			Data:      data,
+			StringMap: map[string]string{},
+			Form:      form,
+		}); err != nil {
+			helpers.ServerError(w, err)
 		}
+		return
+	}
 
+	token, prefix, hash, err := helpers.NewAPIKey()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
 	}
 
-	for name, _ := range r.PostForm {
-		if strings.HasPrefix(name, "add_block") {
-			exploded := strings.Split(name, "_")
-			roomID, _ := strconv.Atoi(exploded[2])
-			startDate, _ := time.Parse("2006-01-2", exploded[3])
-			err := this.DB.InsertBlockForRoom(roomID, startDate)
-			if err != nil {
-				helpers.ServerError(w, err)
-				return
-			}
-		}
+	_, err = this.DB.InsertAPIKey(models.APIKey{
+		Name:    form.Get("name"),
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scope:   scope,
+		UserID:  userID,
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
 	}
 
-	this.App.Session.Put(r.Context(), "flash", "Changes saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+	this.App.Session.Put(r.Context(), "new_api_key", token)
+	this.App.Session.Put(r.Context(), "flash", "API key created")
+	http.Redirect(w, r, "/admin/api-keys", http.StatusSeeOther)
 }
 
-// AdminProcessReservation marks a reservation as processed
-func (this *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
-
-	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	src := chi.URLParam(r, "src")
-
-	_ = this.DB.UpdateProcessedForReservation(id, 1)
+// AdminRevokeAPIKey revokes an API key so it can no longer be used
+func (this *Repository) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 
-	this.App.Session.Put(r.Context(), "flash", "Reservation marked as processed")
-
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
 
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	err = this.DB.RevokeAPIKey(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
 	}
 
+	this.App.Session.Put(r.Context(), "flash", "API key revoked")
+	http.Redirect(w, r, "/admin/api-keys", http.StatusSeeOther)
 }
 
-// AdminProcessReservation deletes a reservation
-func (this *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
+// AdminAPIKeyCalls shows the audit of recent calls made with an API key
+func (this *Repository) AdminAPIKeyCalls(w http.ResponseWriter, r *http.Request) {
 
-	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	src := chi.URLParam(r, "src")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
 
-	_ = this.DB.DeleteReservation(id)
+	key, err := this.DB.GetAPIKeyByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-	this.App.Session.Put(r.Context(), "flash", "Reservation deleted")
+	calls, err := this.DB.RecentAPIKeyCalls(id, 200)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	data := make(map[string]interface{})
+	data["key"] = key
+	data["calls"] = calls
 
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	if err := render.Template(w, r, "admin-api-key-calls.page.tmpl", &models.TemplateData{
+		Data: data,
+	}); err != nil {
+		helpers.ServerError(w, err)
 	}
 }
@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/forms"
 )
 
 type postData struct {
@@ -34,9 +41,42 @@ var theTests = []struct {
 	{"login", "/user/login", "GET", http.StatusOK},
 	{"logout", "/user/logout", "GET", http.StatusOK},
 	{"dashboard", "/admin/dashboard", "GET", http.StatusOK},
+	{"today", "/admin/today", "GET", http.StatusOK},
+	{"trash", "/admin/trash", "GET", http.StatusOK},
+	{"audit log", "/admin/audit?entity=reservation&action=update", "GET", http.StatusOK},
+	{"audit log bad filter", "/admin/audit?entity=password", "GET", http.StatusOK},
+	{"audit log error", "/admin/audit?entity_id=1000", "GET", http.StatusInternalServerError},
+	{"calendar", "/admin/reservations-calendar?view=week&date=2026-11-04", "GET", http.StatusOK},
+	{"calendar old link", "/admin/reservations-calendar?y=2026&m=11", "GET", http.StatusOK},
+	{"calendar data", "/admin/reservations-calendar/data?view=quarter&date=2026-11-04", "GET", http.StatusOK},
+	{"calendar data bad view", "/admin/reservations-calendar/data?view=year", "GET", http.StatusBadRequest},
+	{"calendar data error", "/admin/reservations-calendar/data?date=1000-01-01", "GET", http.StatusInternalServerError},
+	{"today print", "/admin/today/print?date=2026-11-02", "GET", http.StatusOK},
 	{"new res", "/admin/reservations-new", "GET", http.StatusOK},
 	{"all res", "/admin/reservations-all", "GET", http.StatusOK},
+	{"filtered res", "/admin/reservations-all?from=2026-11-01&to=2026-11-30&room_id=1&status=processed", "GET", http.StatusOK},
+	{"res with bad filter", "/admin/reservations-all?from=yesterday", "GET", http.StatusOK},
+	{"searched res", "/admin/reservations-new?q=smith&sort=-last_name&page=2&per_page=10", "GET", http.StatusOK},
+	{"export res", "/admin/reservations/export?format=xlsx&status=new", "GET", http.StatusOK},
+	{"export res bad format", "/admin/reservations/export?format=pdf", "GET", http.StatusBadRequest},
+	{"export res failing query", "/admin/reservations/export?room_id=1000", "GET", http.StatusInternalServerError},
 	{"show res", "/admin/reservations/new/1/show", "GET", http.StatusOK},
+	{"api keys", "/admin/api-keys", "GET", http.StatusOK},
+	{"api key calls", "/admin/api-keys/1/calls", "GET", http.StatusOK},
+	{"missing api key calls", "/admin/api-keys/1000/calls", "GET", http.StatusNotFound},
+	{"rooms", "/admin/rooms", "GET", http.StatusOK},
+	{"ical feed", "/rooms/2/calendar/secret.ics", "GET", http.StatusOK},
+	{"ical feed wrong secret", "/rooms/2/calendar/guess.ics", "GET", http.StatusNotFound},
+	{"ical feed missing room", "/rooms/404/calendar/secret.ics", "GET", http.StatusNotFound},
+	{"ical feeds", "/admin/ical-feeds", "GET", http.StatusOK},
+	{"show ical feed", "/admin/ical-feeds/1", "GET", http.StatusOK},
+	{"missing ical feed", "/admin/ical-feeds/1000", "GET", http.StatusNotFound},
+	{"import", "/admin/import", "GET", http.StatusOK},
+	{"webhooks", "/admin/webhooks", "GET", http.StatusOK},
+	{"show webhook", "/admin/webhooks/1", "GET", http.StatusOK},
+	{"missing webhook", "/admin/webhooks/1000", "GET", http.StatusNotFound},
+	{"unknown page", "/no-such-page", "GET", http.StatusNotFound},
+	{"unknown admin page", "/admin/no-such-page", "GET", http.StatusNotFound},
 }
 
 func TestHandlers(t *testing.T) {
@@ -414,3 +454,960 @@ func getCtx(req *http.Request) context.Context {
 
 	return ctx
 }
+
+func TestRepository_AdminPostAPIKey(t *testing.T) {
+
+	postedData := url.Values{}
+	postedData.Add("name", "housekeeping")
+	postedData.Add("scope", "write")
+
+	req, _ := http.NewRequest("POST", "/admin/api-keys", strings.NewReader(postedData.Encode()))
+	ctx := getCtx(req)
+	session.Put(ctx, "user_id", 1)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostAPIKey)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminPostAPIKey returned wrong response code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	if key := session.GetString(ctx, "new_api_key"); len(key) != 67 {
+		t.Errorf("expected a new key to be put in the session, got %q", key)
+	}
+
+	// test for invalid scope
+	postedData = url.Values{}
+	postedData.Add("name", "housekeeping")
+	postedData.Add("scope", "admin")
+
+	req, _ = http.NewRequest("POST", "/admin/api-keys", strings.NewReader(postedData.Encode()))
+	ctx = getCtx(req)
+	session.Put(ctx, "user_id", 1)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminPostAPIKey returned wrong response code for invalid scope: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	// test for no logged in user
+	postedData = url.Values{}
+	postedData.Add("name", "housekeeping")
+	postedData.Add("scope", "write")
+
+	req, _ = http.NewRequest("POST", "/admin/api-keys", strings.NewReader(postedData.Encode()))
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("AdminPostAPIKey returned wrong response code without a user: got %d, wanted %d", rr.Code, http.StatusForbidden)
+	}
+
+	if key := session.GetString(ctx, "new_api_key"); key != "" {
+		t.Errorf("expected no key without a user, got %q", key)
+	}
+}
+
+func TestRepository_AdminPostWebhook(t *testing.T) {
+
+	postedData := url.Values{}
+	postedData.Add("url", "https://example.com/hooks")
+	postedData.Add("events", "reservation.created")
+	postedData.Add("events", "block.deleted")
+
+	req, _ := http.NewRequest("POST", "/admin/webhooks", strings.NewReader(postedData.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostWebhook)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminPostWebhook returned wrong response code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	// test for bad url and unknown event
+	postedData = url.Values{}
+	postedData.Add("url", "ftp://example.com")
+	postedData.Add("events", "reservation.deleted")
+
+	req, _ = http.NewRequest("POST", "/admin/webhooks", strings.NewReader(postedData.Encode()))
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminPostWebhook returned wrong response code for invalid form: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestRepository_AdminRedeliverWebhook(t *testing.T) {
+
+	tests := []struct {
+		id           string
+		expectedCode int
+		expectedURL  string
+	}{
+		{"1", http.StatusSeeOther, "/admin/webhooks/1"},
+		{"1000", http.StatusNotFound, ""},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/webhooks/deliveries/"+e.id+"/redeliver", nil)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+
+		rr := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminRedeliverWebhook)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("AdminRedeliverWebhook(%s) returned wrong response code: got %d, wanted %d", e.id, rr.Code, e.expectedCode)
+		}
+
+		if e.expectedURL != "" && rr.Header().Get("Location") != e.expectedURL {
+			t.Errorf("AdminRedeliverWebhook(%s) redirected to %s, wanted %s", e.id, rr.Header().Get("Location"), e.expectedURL)
+		}
+	}
+}
+
+func TestRepository_RoomICalFeed(t *testing.T) {
+	routes := getRoutes()
+
+	req, _ := http.NewRequest("GET", "/rooms/2/calendar/secret.ics", nil)
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("RoomICalFeed returned wrong response code: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("expected a text/calendar content type, got %s", ct)
+	}
+
+	body := rr.Body.String()
+	if strings.Count(body, "BEGIN:VEVENT") != 2 || !strings.Contains(body, "SUMMARY:Reserved") || !strings.Contains(body, "SUMMARY:Blocked") {
+		t.Errorf("expected a reserved and a blocked event, got\n%s", body)
+	}
+
+	etag := rr.Header().Get("ETag")
+	lastModified := rr.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatal("expected ETag and Last-Modified headers")
+	}
+
+	// a matching ETag is not modified
+	req, _ = http.NewRequest("GET", "/rooms/2/calendar/secret.ics", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("RoomICalFeed with matching ETag: got %d, wanted %d", rr.Code, http.StatusNotModified)
+	}
+
+	// removing the block leaves Last-Modified where it was, so If-Modified-Since alone still gets the feed
+	req, _ = http.NewRequest("GET", "/rooms/3/calendar/secret.ics", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("RoomICalFeed with If-Modified-Since after a delete: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+	if rr.Header().Get("Last-Modified") != lastModified {
+		t.Errorf("expected Last-Modified to stay %s after a delete, got %s", lastModified, rr.Header().Get("Last-Modified"))
+	}
+	if strings.Contains(rr.Body.String(), "SUMMARY:Blocked") {
+		t.Errorf("expected the removed block to be gone from the feed, got\n%s", rr.Body.String())
+	}
+
+	// a stale ETag gets the feed
+	req, _ = http.NewRequest("GET", "/rooms/2/calendar/secret.ics", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("RoomICalFeed with stale ETag: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestRepository_AdminRotateRoomICalSecret(t *testing.T) {
+
+	tests := []struct {
+		id           string
+		expectedCode int
+	}{
+		{"2", http.StatusSeeOther},
+		{"1", http.StatusInternalServerError},
+		{"1000", http.StatusInternalServerError},
+		{"404", http.StatusNotFound},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/rooms/"+e.id+"/rotate-ical-secret", nil)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+
+		rr := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminRotateRoomICalSecret)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("AdminRotateRoomICalSecret(%s) returned wrong response code: got %d, wanted %d", e.id, rr.Code, e.expectedCode)
+		}
+	}
+}
+
+func TestRepository_AdminPostICalFeed(t *testing.T) {
+
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;VALUE=DATE:20261102\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	}))
+	defer channel.Close()
+
+	postedData := url.Values{}
+	postedData.Add("room_id", "2")
+	postedData.Add("name", "Channel")
+	postedData.Add("url", channel.URL)
+
+	req, _ := http.NewRequest("POST", "/admin/ical-feeds", strings.NewReader(postedData.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostICalFeed)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminPostICalFeed returned wrong response code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	if msg := session.GetString(ctx, "flash"); msg != "Imported: 1 added, 0 updated, 0 removed" {
+		t.Errorf("unexpected flash message %q", msg)
+	}
+
+	// test for missing url
+	postedData = url.Values{}
+	postedData.Add("room_id", "2")
+	postedData.Add("name", "Channel")
+
+	req, _ = http.NewRequest("POST", "/admin/ical-feeds", strings.NewReader(postedData.Encode()))
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminPostICalFeed returned wrong response code for missing url: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestRepository_AdminExportReservations(t *testing.T) {
+	routes := getRoutes()
+
+	req, _ := http.NewRequest("GET", "/admin/reservations/export?status=processed", nil)
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("AdminExportReservations returned wrong response code: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("expected an attachment, got %s", cd)
+	}
+
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(rr.Body.String(), "\ufeff")), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a header and one processed reservation, got %q", lines)
+	}
+
+	if !strings.HasPrefix(lines[0], "ID,First Name,Last Name") {
+		t.Errorf("unexpected header row %s", lines[0])
+	}
+
+	if !strings.HasPrefix(lines[1], "2,Jane,Doe,") || !strings.Contains(lines[1], ",2026-11-09,2026-11-11,2,processed,") {
+		t.Errorf("unexpected reservation row %s", lines[1])
+	}
+}
+
+func TestRepository_AdminImport(t *testing.T) {
+
+	file := "type,first_name,last_name,email,phone,room,start_date,end_date\n" +
+		"reservation,John,Smith,john@here.com,,General's Quarters,2026-01-05,2026-01-08\n" +
+		"block,,,,,Major's Suite,2026-01-05,2026-01-07\n" +
+		"reservation,Jane,Doe,jane@here.com,,Penthouse,2026-01-05,2026-01-08\n"
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "old-bookings.csv")
+	_, _ = fw.Write([]byte(file))
+	_ = mw.Close()
+
+	req, _ := http.NewRequest("POST", "/admin/import", &body)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostImport)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("AdminPostImport returned wrong response code: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	if !strings.Contains(rr.Body.String(), "2 rows can be imported") || !strings.Contains(rr.Body.String(), "no room called") {
+		t.Error("expected the dry run report to list valid rows and errors")
+	}
+
+	if session.GetString(ctx, "import_csv") != file || session.GetString(ctx, "import_lines") != "2,3" {
+		t.Error("expected the checked file and its valid lines to be kept in the session")
+	}
+
+	// confirm the import with the same session
+	req, _ = http.NewRequest("POST", "/admin/import/commit", nil)
+	req = req.WithContext(ctx)
+
+	rr = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.AdminCommitImport)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminCommitImport returned wrong response code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	if msg := session.GetString(ctx, "flash"); msg != "Imported 1 reservations and 2 blocked nights, skipped 1 rows with errors" {
+		t.Errorf("unexpected flash message %q", msg)
+	}
+
+	// confirming again has nothing left to import
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Header().Get("Location") != "/admin/import" {
+		t.Errorf("expected a second confirmation to go back to the upload form, got %s", rr.Header().Get("Location"))
+	}
+
+	// rows that changed since the dry run are shown again rather than imported
+	session.Put(ctx, "import_csv", file)
+	session.Put(ctx, "import_lines", "2")
+	session.Remove(ctx, "flash")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Nothing was imported") || !strings.Contains(rr.Body.String(), "2 rows can be imported") {
+		t.Errorf("expected the new dry run report, got status %d", rr.Code)
+	}
+
+	if msg := session.GetString(ctx, "flash"); msg != "" {
+		t.Errorf("expected nothing to be imported, got flash %q", msg)
+	}
+
+	if session.GetString(ctx, "import_csv") != file || session.GetString(ctx, "import_lines") != "2,3" {
+		t.Error("expected the file to be kept with the new valid lines to confirm again")
+	}
+}
+
+func TestRepository_AdminAllReservationsQuery(t *testing.T) {
+	routes := getRoutes()
+
+	tests := []struct {
+		name     string
+		url      string
+		contains []string
+		excludes []string
+	}{
+		{"search", "/admin/reservations-all?q=jane", []string{"Doe", "1 reservations, page 1 of 1"}, []string{"Smith"}},
+		{"paged", "/admin/reservations-all?per_page=1", []string{"2 reservations, page 1 of 2", "page=2"}, nil},
+		{"second page", "/admin/reservations-all?per_page=1&page=2", []string{"page 2 of 2", "page=1"}, nil},
+		{"sort toggles", "/admin/reservations-all?sort=last_name", []string{"sort=-last_name", "▲"}, nil},
+		{"bad sort", "/admin/reservations-all?sort=password", []string{"Unknown sort", "0 reservations"}, nil},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", e.url, nil)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: got status %d", e.name, rr.Code)
+			continue
+		}
+
+		body := rr.Body.String()
+		for _, c := range e.contains {
+			if !strings.Contains(body, c) {
+				t.Errorf("%s: expected page to contain %q", e.name, c)
+			}
+		}
+		for _, c := range e.excludes {
+			if strings.Contains(body, c) {
+				t.Errorf("%s: expected page not to contain %q", e.name, c)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminDashboard(t *testing.T) {
+	routes := getRoutes()
+
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+		contains   []string
+	}{
+		{"page", "/admin/dashboard?from=2026-11-01&to=2026-11-30", http.StatusOK, []string{"25.0%", "5 of 20 room nights", "2.5 nights", `value="2026-11-01"`}},
+		{"page bad range", "/admin/dashboard?from=2026-11-30&to=2026-11-01", http.StatusOK, []string{"Must not be before the from date"}},
+		{"page error", "/admin/dashboard?from=1000-01-01&to=1000-02-01", http.StatusInternalServerError, nil},
+		{"summary", "/admin/dashboard/summary.json?from=2026-11-01&to=2026-11-30", http.StatusOK, []string{`"occupancy": 25`, `"nights_sold": 5`}},
+		{"summary bad date", "/admin/dashboard/summary.json?from=soon", http.StatusBadRequest, []string{"Invalid date"}},
+		{"summary too long", "/admin/dashboard/summary.json?from=2020-01-01&to=2026-01-01", http.StatusBadRequest, []string{"at most"}},
+		{"occupancy", "/admin/dashboard/occupancy.json?from=2026-11-01&to=2026-11-30", http.StatusOK, []string{`"2026-11"`, `"Major's Suite"`, "50"}},
+		{"occupancy error", "/admin/dashboard/occupancy.json?from=1000-01-01&to=1000-02-01", http.StatusInternalServerError, nil},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", e.url, nil)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+			continue
+		}
+
+		body := rr.Body.String()
+		for _, c := range e.contains {
+			if !strings.Contains(body, c) {
+				t.Errorf("%s: expected response to contain %q", e.name, c)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminToday(t *testing.T) {
+	routes := getRoutes()
+
+	req, _ := http.NewRequest("GET", "/admin/today?date=2026-11-02", nil)
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("AdminToday returned wrong response code: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	for _, c := range []string{"Monday, 2 November 2026", "John Smith", "Jane Doe", "Ann Lee", "Fully Booked Room",
+		"/admin/today/1/check-in", "/admin/today/2/check-out", "date=2026-11-01", "date=2026-11-03"} {
+		if !strings.Contains(rr.Body.String(), c) {
+			t.Errorf("AdminToday: expected page to contain %q", c)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/today?date=1000-01-01", nil)
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("AdminToday with failing query: got %d, wanted %d", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestRepository_AdminCheckInOut(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		id           string
+		expectedCode int
+		flash        string
+		error        string
+	}{
+		{"check in", Repo.AdminCheckIn, "1", http.StatusSeeOther, "Checked in", ""},
+		{"check in twice", Repo.AdminCheckIn, "3", http.StatusSeeOther, "", "Guest is already checked in"},
+		{"check in missing", Repo.AdminCheckIn, "1000", http.StatusNotFound, "", ""},
+		{"check in bad id", Repo.AdminCheckIn, "x", http.StatusNotFound, "", ""},
+		{"check in fails", Repo.AdminCheckIn, "1002", http.StatusInternalServerError, "", ""},
+		{"check out", Repo.AdminCheckOut, "3", http.StatusSeeOther, "Checked out", ""},
+		{"check out not in", Repo.AdminCheckOut, "1", http.StatusSeeOther, "", "Guest has not checked in"},
+		{"check out twice", Repo.AdminCheckOut, "4", http.StatusSeeOther, "", "Guest is already checked out"},
+	}
+
+	for _, e := range tests {
+		postedData := url.Values{}
+		postedData.Add("date", "2026-11-02")
+
+		req, _ := http.NewRequest("POST", "/admin/today/"+e.id+"/check-in", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		ctx := context.WithValue(getCtx(req), chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: got status %d, wanted %d", e.name, rr.Code, e.expectedCode)
+			continue
+		}
+
+		if rr.Code != http.StatusSeeOther {
+			continue
+		}
+
+		if loc := rr.Header().Get("Location"); loc != "/admin/today?date=2026-11-02" {
+			t.Errorf("%s: redirected to %q", e.name, loc)
+		}
+
+		if msg := session.GetString(ctx, "flash"); !strings.HasPrefix(msg, e.flash) || (e.flash == "") != (msg == "") {
+			t.Errorf("%s: unexpected flash message %q", e.name, msg)
+		}
+
+		if msg := session.GetString(ctx, "error"); msg != e.error {
+			t.Errorf("%s: unexpected error message %q", e.name, msg)
+		}
+	}
+}
+
+func TestCalendarSpanFromForm(t *testing.T) {
+	now := time.Date(2026, 11, 4, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		start string
+		end   string
+		prev  string
+		valid bool
+	}{
+		{"", "2026-11-01", "2026-12-01", "2026-10-01", true},
+		{"view=week", "2026-11-02", "2026-11-09", "2026-10-26", true},
+		{"view=week&date=2026-11-08", "2026-11-02", "2026-11-09", "2026-10-26", true},
+		{"view=quarter&date=2026-12-31", "2026-12-01", "2027-03-01", "2026-09-01", true},
+		{"y=2027&m=02", "2027-02-01", "2027-03-01", "2027-01-01", true},
+		{"y=2027&m=13", "2026-11-01", "2026-12-01", "2026-10-01", true},
+		{"view=year", "2026-11-01", "2026-12-01", "2026-10-01", false},
+		{"date=soon", "2026-11-01", "2026-12-01", "2026-10-01", false},
+	}
+
+	for _, e := range tests {
+		values, _ := url.ParseQuery(e.query)
+		form := forms.New(values)
+
+		c := calendarSpanFromForm(form, now)
+
+		got := []string{c.Start.Format(apiDateLayout), c.End.Format(apiDateLayout), c.Prev.Format(apiDateLayout)}
+		want := []string{e.start, e.end, e.prev}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%q: got %v, wanted %v", e.query, got, want)
+		}
+
+		if form.Valid() != e.valid {
+			t.Errorf("%q: expected valid to be %v", e.query, e.valid)
+		}
+	}
+}
+
+func TestRepository_AdminCalendarData(t *testing.T) {
+	routes := getRoutes()
+
+	req, _ := http.NewRequest("GET", "/admin/reservations-calendar/data?view=month&date=2026-11-04", nil)
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	var resp calendarResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("cannot parse calendar data: %s", err)
+	}
+
+	if len(resp.Days) != 30 || resp.Days[0] != "2026-11-01" || resp.End != "2026-12-01" {
+		t.Errorf("unexpected days %v to %s", resp.Days, resp.End)
+	}
+
+	rooms := make(map[int]calendarRoom)
+	for _, room := range resp.Rooms {
+		rooms[room.ID] = room
+	}
+
+	if res := rooms[2].Reservations; len(res) != 1 || res[0].Guest != "John Smith" || res[0].Start != "2026-11-02" || res[0].End != "2026-11-05" {
+		t.Errorf("unexpected reservations for room 2: %+v", res)
+	}
+
+	if len(rooms[3].Blocks) != 1 || len(rooms[3].Reservations) != 0 {
+		t.Errorf("unexpected stays for room 3: %+v", rooms[3])
+	}
+
+	if len(rooms[1002].External) != 1 || len(rooms[1002].Blocks) != 0 {
+		t.Errorf("unexpected stays for room 1002: %+v", rooms[1002])
+	}
+}
+
+func TestRepository_AdminCalendarActions(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		id           string
+		posted       string
+		expectedCode int
+	}{
+		{"block", Repo.AdminCalendarBlock, "", "room_id=2&date=2026-11-04", http.StatusCreated},
+		{"block taken night", Repo.AdminCalendarBlock, "", "room_id=1002&date=2026-11-04", http.StatusConflict},
+		{"block without date", Repo.AdminCalendarBlock, "", "room_id=2", http.StatusUnprocessableEntity},
+		{"block fails", Repo.AdminCalendarBlock, "", "room_id=1000&date=2026-11-04", http.StatusInternalServerError},
+		{"unblock", Repo.AdminCalendarUnblock, "7", "", http.StatusOK},
+		{"unblock bad id", Repo.AdminCalendarUnblock, "x", "", http.StatusNotFound},
+		{"unblock missing or not a block", Repo.AdminCalendarUnblock, "404", "", http.StatusNotFound},
+		{"unblock lookup fails", Repo.AdminCalendarUnblock, "1000", "", http.StatusInternalServerError},
+		{"unblock fails", Repo.AdminCalendarUnblock, "1001", "", http.StatusInternalServerError},
+		{"move", Repo.AdminCalendarMoveReservation, "1", "room_id=2&start_date=2026-11-10", http.StatusOK},
+		{"move onto booking", Repo.AdminCalendarMoveReservation, "1", "room_id=1002&start_date=2026-11-10", http.StatusConflict},
+		{"move to missing room", Repo.AdminCalendarMoveReservation, "1", "room_id=404&start_date=2026-11-10", http.StatusUnprocessableEntity},
+		{"move bad date", Repo.AdminCalendarMoveReservation, "1", "room_id=2&start_date=soon", http.StatusUnprocessableEntity},
+		{"move missing reservation", Repo.AdminCalendarMoveReservation, "1000", "room_id=2&start_date=2026-11-10", http.StatusNotFound},
+		{"move fails", Repo.AdminCalendarMoveReservation, "1003", "room_id=2&start_date=2026-11-10", http.StatusInternalServerError},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/reservations-calendar", strings.NewReader(e.posted))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+
+		rr := httptest.NewRecorder()
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: got status %d, wanted %d: %s", e.name, rr.Code, e.expectedCode, rr.Body.String())
+		}
+
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected a JSON response, got %q", e.name, ct)
+		}
+	}
+}
+
+func TestRepository_AdminCalendarUnblockUsesStoredBlock(t *testing.T) {
+
+	req, _ := http.NewRequest("POST", "/admin/reservations-calendar", strings.NewReader("room_id=3&date=2030-01-01"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "7")
+	req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	Repo.AdminCalendarUnblock(rr, req)
+
+	var payload blockPayload
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.ID != 7 || payload.RoomID != 2 || payload.Date != "2026-11-04" {
+		t.Errorf("expected the stored block, got %+v", payload)
+	}
+}
+
+func TestRepository_AdminAuditLog(t *testing.T) {
+	routes := getRoutes()
+
+	tests := []struct {
+		name     string
+		url      string
+		contains []string
+		excludes []string
+	}{
+		{"all", "/admin/audit", []string{"3 entries", "john@there.com", "10.0.0.1", "block 7", "API key Channel manager"}, nil},
+		{"by entity", "/admin/audit?entity=block", []string{"1 entries", "block 7"}, []string{"john@there.com"}},
+		{"bad entity", "/admin/audit?entity=password", []string{"Unknown entity", "0 entries"}, nil},
+		{"reservation history", "/admin/reservations/all/1/show", []string{"History (1)", "john@there.com"}, []string{"block 7"}},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", e.url, nil)
+		req.RequestURI = e.url
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: got status %d", e.name, rr.Code)
+			continue
+		}
+
+		body := rr.Body.String()
+		for _, c := range e.contains {
+			if !strings.Contains(body, c) {
+				t.Errorf("%s: expected page to contain %q", e.name, c)
+			}
+		}
+		for _, c := range e.excludes {
+			if strings.Contains(body, c) {
+				t.Errorf("%s: expected page not to contain %q", e.name, c)
+			}
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	req.RemoteAddr = "10.0.0.1:52100"
+	if ip := remoteIP(req); ip != "10.0.0.1" {
+		t.Errorf("got %q for %s", ip, req.RemoteAddr)
+	}
+
+	req.RemoteAddr = "[::1]:52100"
+	if ip := remoteIP(req); ip != "::1" {
+		t.Errorf("got %q for %s", ip, req.RemoteAddr)
+	}
+
+	req.RemoteAddr = "pipe"
+	if ip := remoteIP(req); ip != "pipe" {
+		t.Errorf("got %q for %s", ip, req.RemoteAddr)
+	}
+}
+
+func TestRepository_AdminRestoreReservation(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		id           string
+		expectedCode int
+		flash        string
+		error        string
+	}{
+		{"restored", "5", http.StatusSeeOther, "Reservation restored", ""},
+		{"room taken", "1002", http.StatusSeeOther, "", "The room has been booked for those dates since, move the other stay first"},
+		{"not in trash", "1000", http.StatusNotFound, "", ""},
+		{"bad id", "x", http.StatusNotFound, "", ""},
+		{"lookup fails", "1001", http.StatusInternalServerError, "", ""},
+		{"restore fails", "1003", http.StatusInternalServerError, "", ""},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/trash/"+e.id+"/restore", nil)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		ctx := context.WithValue(getCtx(req), chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Repo.AdminRestoreReservation).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: got status %d, wanted %d", e.name, rr.Code, e.expectedCode)
+			continue
+		}
+
+		if rr.Code != http.StatusSeeOther {
+			continue
+		}
+
+		if loc := rr.Header().Get("Location"); loc != "/admin/trash" {
+			t.Errorf("%s: redirected to %q", e.name, loc)
+		}
+
+		if msg := session.GetString(ctx, "flash"); msg != e.flash {
+			t.Errorf("%s: unexpected flash message %q", e.name, msg)
+		}
+
+		if msg := session.GetString(ctx, "error"); msg != e.error {
+			t.Errorf("%s: unexpected error message %q", e.name, msg)
+		}
+	}
+}
+
+func TestRepository_AdminProcessAndDeleteReservation(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		id           string
+		postedData   string
+		expectedCode int
+		expectedURL  string
+		flash        string
+		error        string
+	}{
+		{"process", Repo.AdminProcessReservation, "1", "", http.StatusSeeOther, "/admin/reservations-new", "Reservation marked as processed", ""},
+		{"process from calendar", Repo.AdminProcessReservation, "1", "year=2026&month=11", http.StatusSeeOther, "/admin/reservations-calendar?y=2026&m=11", "Reservation marked as processed", ""},
+		{"process fails", Repo.AdminProcessReservation, "1003", "", http.StatusSeeOther, "/admin/reservations-new", "", "Can't mark the reservation as processed, please try again"},
+		{"process missing", Repo.AdminProcessReservation, "1000", "", http.StatusNotFound, "", "", ""},
+		{"process bad id", Repo.AdminProcessReservation, "x", "", http.StatusNotFound, "", "", ""},
+		{"process lookup fails", Repo.AdminProcessReservation, "1001", "", http.StatusInternalServerError, "", "", ""},
+		{"delete", Repo.AdminDeleteReservation, "1", "", http.StatusSeeOther, "/admin/reservations-new", "Reservation moved to the trash", ""},
+		{"delete fails", Repo.AdminDeleteReservation, "1003", "", http.StatusSeeOther, "/admin/reservations-new", "", "Can't delete the reservation, please try again"},
+		{"delete missing", Repo.AdminDeleteReservation, "1000", "", http.StatusNotFound, "", "", ""},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/process-reservations/new/"+e.id+"/do", strings.NewReader(e.postedData))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", "new")
+		rctx.URLParams.Add("id", e.id)
+		ctx := context.WithValue(getCtx(req), chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s: got status %d, wanted %d", e.name, rr.Code, e.expectedCode)
+			continue
+		}
+
+		if rr.Code != http.StatusSeeOther {
+			continue
+		}
+
+		if loc := rr.Header().Get("Location"); loc != e.expectedURL {
+			t.Errorf("%s: redirected to %q, wanted %q", e.name, loc, e.expectedURL)
+		}
+
+		if msg := session.GetString(ctx, "flash"); msg != e.flash {
+			t.Errorf("%s: unexpected flash message %q", e.name, msg)
+		}
+
+		if msg := session.GetString(ctx, "error"); msg != e.error {
+			t.Errorf("%s: unexpected error message %q", e.name, msg)
+		}
+	}
+}
+
+func TestAdminReservationActionsRejectGet(t *testing.T) {
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	for _, path := range []string{"/admin/process-reservations/new/1/do", "/admin/delete-reservations/new/1/do"} {
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s: got status %d, wanted %d", path, resp.StatusCode, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		status int
+		want   string
+	}{
+		{"not found", "GET", "/no-such-page", http.StatusNotFound, "Page not found"},
+		{"wrong method", "DELETE", "/about", http.StatusMethodNotAllowed, "Page not found"},
+		{"server error", "GET", "/admin/audit?entity_id=1000", http.StatusInternalServerError, "Something went wrong"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest(e.method, ts.URL+e.url, nil)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := new(bytes.Buffer)
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != e.status {
+			t.Errorf("%s: got status %d, wanted %d", e.name, resp.StatusCode, e.status)
+		}
+
+		// the error pages are rendered through the site layout
+		if !strings.Contains(body.String(), e.want) || !strings.Contains(body.String(), "</html>") {
+			t.Errorf("%s: expected the %q page, got %s", e.name, e.want, body.String())
+		}
+	}
+}
+
+func TestBrokenTemplateAnswersWithServerError(t *testing.T) {
+	good := app.TemplateCache["home.page.tmpl"]
+	defer func() { app.TemplateCache["home.page.tmpl"] = good }()
+
+	// parses fine but fails as soon as it is executed
+	app.TemplateCache["home.page.tmpl"] = template.Must(template.New("home.page.tmpl").Parse(`{{.NoSuchField}}`))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Repo.Home).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, wanted %d", rr.Code, http.StatusInternalServerError)
+	}
+
+	if !strings.Contains(rr.Body.String(), "Something went wrong") {
+		t.Errorf("expected the 500 page, got %s", rr.Body.String())
+	}
+
+	// the process is still here to serve the next request
+	app.TemplateCache["home.page.tmpl"] = good
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.Home).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status %d after the template was fixed, wanted %d", rr.Code, http.StatusOK)
+	}
+}
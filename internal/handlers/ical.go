@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/ical"
+	"github.com/gummy789j/bookings/internal/icalsync"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+)
+
+const icalProdID = "-//Bookings//Room Availability//EN"
+
+// restrictionSummary is the busy event title for a restriction, it never includes guest details
+func restrictionSummary(restrictionID int) string {
+	switch restrictionID {
+	case 1:
+		return "Reserved"
+	case 2:
+		return "Blocked"
+	case icalsync.ExternalBooking:
+		return "Booked elsewhere"
+	default:
+		return "Not available"
+	}
+}
+
+// RoomICalFeed serves the availability of one room as an iCal feed. The secret in the URL
+// is the only authentication, so booking sites can subscribe to it.
+func (this *Repository) RoomICalFeed(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	room, err := this.DB.GetRoomByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	secret := chi.URLParam(r, "secret")
+	if room.ICalSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(room.ICalSecret)) != 1 {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	restrictions, err := this.DB.GetRestrictionsForRoomSince(room.ID, today)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	cal := ical.Calendar{
+		ProdID: icalProdID,
+		Name:   room.RoomName,
+	}
+
+	lastModified := room.UpdatedAt
+	for _, rr := range restrictions {
+		cal.Events = append(cal.Events, ical.Event{
+			UID:     fmt.Sprintf("restriction-%d@bookings", rr.ID),
+			Start:   rr.StartDate,
+			End:     rr.EndDate,
+			Summary: restrictionSummary(rr.RestrictionID),
+			Stamp:   rr.UpdatedAt,
+		})
+
+		if rr.UpdatedAt.After(lastModified) {
+			lastModified = rr.UpdatedAt
+		}
+	}
+
+	body := cal.Marshal()
+
+	// only the ETag decides 304s: deleting a restriction changes the feed without moving lastModified,
+	// so If-Modified-Since alone would keep showing the freed dates as busy
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="room-%d.ics"`, room.ID))
+	_, _ = w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// baseURL is the scheme and host the request was made to, used to show absolute feed URLs
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// AdminRooms lists the rooms with their iCal feed URLs
+func (this *Repository) AdminRooms(w http.ResponseWriter, r *http.Request) {
+
+	rooms, err := this.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	stringMap := make(map[string]string)
+	stringMap["base_url"] = baseURL(r)
+
+	if err := render.Template(w, r, "admin-rooms.page.tmpl", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminRotateRoomICalSecret gives a room a new iCal feed URL, the old one stops working
+func (this *Repository) AdminRotateRoomICalSecret(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	room, err := this.DB.GetRoomByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	after := room
+	after.ICalSecret = hex.EncodeToString(b)
+
+	err = this.DB.UpdateRoomICalSecret(id, after.ICalSecret)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	this.recordAudit(r, audit.ActionUpdate, audit.EntityRoom, id, audit.Room(room), audit.Room(after))
+
+	this.App.Session.Put(r.Context(), "flash", "Feed URL changed, update it on the booking sites")
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// AdminICalFeeds lists the external calendars and the bookings that clash with them
+func (this *Repository) AdminICalFeeds(w http.ResponseWriter, r *http.Request) {
+	this.renderICalFeeds(w, r, forms.New(nil))
+}
+
+func (this *Repository) renderICalFeeds(w http.ResponseWriter, r *http.Request, form *forms.Forms) {
+
+	feeds, err := this.DB.AllICalFeeds()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := this.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	conflicts, err := this.DB.ICalConflicts(0)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["feeds"] = feeds
+	data["rooms"] = rooms
+	data["conflicts"] = conflicts
+
+	if err := render.Template(w, r, "admin-ical-feeds.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminPostICalFeed registers an external calendar for a room and imports it
+func (this *Repository) AdminPostICalFeed(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "name", "url")
+	form.IsInt("room_id")
+
+	u, err := url.Parse(form.Get("url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		form.Errors.Add("url", "Must be an http or https URL")
+	}
+
+	if !form.Valid() {
+		this.renderICalFeeds(w, r, form)
+		return
+	}
+
+	roomID, _ := strconv.Atoi(form.Get("room_id"))
+
+	feed := models.ICalFeed{
+		RoomID: roomID,
+		Name:   form.Get("name"),
+		URL:    form.Get("url"),
+	}
+
+	feed.ID, err = this.DB.InsertICalFeed(feed)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	run := icalsync.NewImporter(this.DB, this.App.Logger).Import(feed)
+	this.putImportRunMessage(r, run)
+
+	http.Redirect(w, r, "/admin/ical-feeds", http.StatusSeeOther)
+}
+
+// icalFeedFromURL loads the feed named by the {id} URL parameter, writing an error response if it can't
+func (this *Repository) icalFeedFromURL(w http.ResponseWriter, r *http.Request) (models.ICalFeed, bool) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.ICalFeed{}, false
+	}
+
+	feed, err := this.DB.GetICalFeedByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return feed, false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return feed, false
+	}
+
+	return feed, true
+}
+
+// AdminShowICalFeed shows an external calendar with its import log and conflicts
+func (this *Repository) AdminShowICalFeed(w http.ResponseWriter, r *http.Request) {
+
+	feed, ok := this.icalFeedFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := this.DB.ICalImportRuns(feed.ID, 50)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	conflicts, err := this.DB.ICalConflicts(feed.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["feed"] = feed
+	data["runs"] = runs
+	data["conflicts"] = conflicts
+
+	if err := render.Template(w, r, "admin-ical-feed-show.page.tmpl", &models.TemplateData{
+		Data: data,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminImportICalFeed imports an external calendar now instead of waiting for the next run
+func (this *Repository) AdminImportICalFeed(w http.ResponseWriter, r *http.Request) {
+
+	feed, ok := this.icalFeedFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	run := icalsync.NewImporter(this.DB, this.App.Logger).Import(feed)
+	this.putImportRunMessage(r, run)
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/ical-feeds/%d", feed.ID), http.StatusSeeOther)
+}
+
+// putImportRunMessage flashes the outcome of an import
+func (this *Repository) putImportRunMessage(r *http.Request, run models.ICalImportRun) {
+	if run.Error != "" {
+		this.App.Session.Put(r.Context(), "error", "Import failed: "+run.Error)
+		return
+	}
+
+	msg := fmt.Sprintf("Imported: %d added, %d updated, %d removed", run.Added, run.Updated, run.Removed)
+	if run.Conflicts > 0 {
+		this.App.Session.Put(r.Context(), "warning", fmt.Sprintf("%s. %d external bookings clash with reservations", msg, run.Conflicts))
+		return
+	}
+
+	this.App.Session.Put(r.Context(), "flash", msg)
+}
+
+// AdminDeleteICalFeed stops importing an external calendar and frees the dates it blocked
+func (this *Repository) AdminDeleteICalFeed(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = this.DB.DeleteICalFeed(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	this.App.Session.Put(r.Context(), "flash", "Feed deleted, its blocks were removed")
+	http.Redirect(w, r, "/admin/ical-feeds", http.StatusSeeOther)
+}
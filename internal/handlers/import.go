@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/bulkimport"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+)
+
+// maxImportSize is the largest CSV file accepted for import
+const maxImportSize = 2 << 20
+
+// AdminImport shows the bulk import upload form
+func (this *Repository) AdminImport(w http.ResponseWriter, r *http.Request) {
+	if err := render.Template(w, r, "admin-import.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: map[string]interface{}{"columns": strings.Join(bulkimport.Columns, ",")},
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminPostImport checks an uploaded CSV file and shows what would be imported. Nothing
+// is saved yet; the file is kept in the session until the import is confirmed.
+func (this *Repository) AdminPostImport(w http.ResponseWriter, r *http.Request) {
+
+	form := forms.New(nil)
+	data := map[string]interface{}{"columns": strings.Join(bulkimport.Columns, ",")}
+
+	renderForm := func() {
+		if err := render.Template(w, r, "admin-import.page.tmpl", &models.TemplateData{
+			Form: form,
+			Data: data,
+		}); err != nil {
+			helpers.ServerError(w, err)
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+4096)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		form.Errors.Add("file", "Choose a CSV file of at most 2 MB")
+		renderForm()
+		return
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		form.Errors.Add("file", "Choose a CSV file of at most 2 MB")
+		renderForm()
+		return
+	}
+
+	report, err := bulkimport.Check(strings.NewReader(string(content)), this.DB)
+	if err != nil {
+		form.Errors.Add("file", "Could not read the file: "+err.Error())
+		renderForm()
+		return
+	}
+
+	this.keepImport(r, string(content), report)
+
+	data["report"] = report
+	renderForm()
+}
+
+// keepImport puts a checked file in the session until the import is confirmed, along with which rows the
+// admin was shown as valid
+func (this *Repository) keepImport(r *http.Request, content string, report bulkimport.Report) {
+	if report.Valid > 0 {
+		this.App.Session.Put(r.Context(), "import_csv", content)
+		this.App.Session.Put(r.Context(), "import_lines", report.ValidLines())
+	} else {
+		this.App.Session.Remove(r.Context(), "import_csv")
+		this.App.Session.Remove(r.Context(), "import_lines")
+	}
+}
+
+// AdminCommitImport saves the valid rows of the checked file in one transaction. The file is
+// checked again first, since rooms may have been booked since the dry run. If that changes which
+// rows are valid, the new report is shown to confirm again instead.
+func (this *Repository) AdminCommitImport(w http.ResponseWriter, r *http.Request) {
+
+	content := this.App.Session.PopString(r.Context(), "import_csv")
+	approved := this.App.Session.PopString(r.Context(), "import_lines")
+	if content == "" {
+		this.App.Session.Put(r.Context(), "error", "Nothing to import, upload the file again")
+		http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+		return
+	}
+
+	report, err := bulkimport.Check(strings.NewReader(content), this.DB)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if report.ValidLines() != approved {
+		this.keepImport(r, content, report)
+
+		if err := render.Template(w, r, "admin-import.page.tmpl", &models.TemplateData{
+			Form: forms.New(nil),
+			Data: map[string]interface{}{
+				"columns": strings.Join(bulkimport.Columns, ","),
+				"report":  report,
+				"changed": true,
+			},
+		}); err != nil {
+			helpers.ServerError(w, err)
+		}
+		return
+	}
+
+	reservations, blocks := report.Split()
+
+	err = this.DB.ImportReservations(reservations, blocks)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	this.recordAudit(r, audit.ActionImport, audit.EntityReservation, 0, nil, audit.Snapshot{
+		"reservations":   len(reservations),
+		"blocked_nights": len(blocks),
+	})
+
+	msg := fmt.Sprintf("Imported %d reservations and %d blocked nights", len(reservations), len(blocks))
+	if report.Invalid > 0 {
+		msg += fmt.Sprintf(", skipped %d rows with errors", report.Invalid)
+	}
+
+	this.App.Session.Put(r.Context(), "flash", msg)
+	http.Redirect(w, r, "/admin/reservations-all", http.StatusSeeOther)
+}
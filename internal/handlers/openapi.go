@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/openapi"
+)
+
+// OpenAPIDocument describes every JSON endpoint. Schemas are generated from the
+// response types used by the handlers, so adding a field there updates the spec.
+func OpenAPIDocument() *openapi.Document {
+
+	doc := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title:       "Bookings API",
+			Description: "Rooms, availability and reservations. Calls made with an API key send it as \"Authorization: Bearer <key>\" and need no CSRF token.",
+			Version:     "1.0.0",
+		},
+		Servers: []openapi.Server{{URL: "/"}},
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{
+				"Room":               openapi.SchemaOf(roomResponse{}),
+				"Reservation":        openapi.SchemaOf(reservationResponse{}),
+				"ReservationRequest": openapi.SchemaOf(reservationRequest{}),
+				"Availability":       openapi.SchemaOf(availabilityResponse{}),
+				"LegacyAvailability": openapi.SchemaOf(jsonResponse{}),
+				"Error":              openapi.SchemaOf(helpers.JSONError{}),
+			},
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				"bearerAuth":  {Type: "http", Scheme: "bearer"},
+				"sessionAuth": {Type: "apiKey", In: "cookie", Name: "session"},
+			},
+		},
+	}
+
+	errorResponse := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Content: openapi.JSON(openapi.Ref("Error"))}
+	}
+
+	dateParam := func(name, description string) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Required: true,
+			Schema: &openapi.Schema{Type: "string", Format: "date"}}
+	}
+
+	idParam := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}}
+
+	// bearer and cookie schemes take no scopes, the scope an API key needs is in each operation's description
+	auth := []map[string][]string{{"bearerAuth": {}}, {"sessionAuth": {}}}
+
+	// booking is open to anyone, an API key sent along must still be allowed to write
+	optionalAuth := []map[string][]string{{"bearerAuth": {}}, {"sessionAuth": {}}, {}}
+
+	const (
+		readScope  = "Needs a logged in user, or an API key with read or write scope."
+		writeScope = "Needs a logged in user, or an API key with write scope."
+	)
+
+	doc.Add("GET", "/api/openapi.json", &openapi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "This document",
+		Tags:        []string{"meta"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "OpenAPI 3 document", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+		},
+	})
+
+	doc.Add("GET", "/api/v1/rooms", &openapi.Operation{
+		OperationID: "listRooms",
+		Summary:     "List all rooms",
+		Tags:        []string{"rooms"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "All rooms", Content: openapi.JSON(&openapi.Schema{Type: "array", Items: openapi.Ref("Room")})},
+			"500": errorResponse("Server error"),
+		},
+	})
+
+	doc.Add("GET", "/api/v1/availability", &openapi.Operation{
+		OperationID: "getAvailability",
+		Summary:     "Available rooms for a date range, or whether one room is available when room_id is given",
+		Tags:        []string{"availability"},
+		Parameters: []openapi.Parameter{
+			dateParam("start", "Arrival date, YYYY-MM-DD"),
+			dateParam("end", "Departure date, YYYY-MM-DD, after start"),
+			{Name: "room_id", In: "query", Description: "Only check this room", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Availability", Content: openapi.JSON(openapi.Ref("Availability"))},
+			"404": errorResponse("Room not found"),
+			"422": errorResponse("Invalid query parameters"),
+			"500": errorResponse("Server error"),
+		},
+	})
+
+	doc.Add("POST", "/api/v1/reservations", &openapi.Operation{
+		OperationID: "createReservation",
+		Summary:     "Book a room",
+		Description: "Open to anyone. An API key sent along needs write scope.",
+		Tags:        []string{"reservations"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("ReservationRequest"))},
+		Security:    optionalAuth,
+		Responses: map[string]openapi.Response{
+			"201": {Description: "Reservation created", Content: openapi.JSON(openapi.Ref("Reservation"))},
+			"400": errorResponse("Body is not valid JSON"),
+			"401": errorResponse("Invalid or revoked API key"),
+			"403": errorResponse("API key lacks write scope"),
+			"409": errorResponse("Room is not available for these dates"),
+			"422": errorResponse("Invalid reservation"),
+			"500": errorResponse("Server error"),
+		},
+	})
+
+	doc.Add("GET", "/api/v1/reservations/{id}", &openapi.Operation{
+		OperationID: "getReservation",
+		Summary:     "Get one reservation",
+		Description: readScope,
+		Tags:        []string{"reservations"},
+		Parameters:  []openapi.Parameter{idParam},
+		Security:    auth,
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The reservation", Content: openapi.JSON(openapi.Ref("Reservation"))},
+			"401": errorResponse("Authentication required"),
+			"403": errorResponse("API key lacks read scope"),
+			"404": errorResponse("Reservation not found"),
+			"500": errorResponse("Server error"),
+		},
+	})
+
+	doc.Add("DELETE", "/api/v1/reservations/{id}", &openapi.Operation{
+		OperationID: "cancelReservation",
+		Summary:     "Cancel a reservation and free its dates",
+		Description: writeScope,
+		Tags:        []string{"reservations"},
+		Parameters:  []openapi.Parameter{idParam},
+		Security:    auth,
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Reservation cancelled"},
+			"401": errorResponse("Authentication required"),
+			"403": errorResponse("API key lacks write scope"),
+			"404": errorResponse("Reservation not found"),
+			"500": errorResponse("Server error"),
+		},
+	})
+
+	doc.Add("POST", "/search-availability-json", &openapi.Operation{
+		OperationID: "legacyAvailability",
+		Summary:     "Whether a room is available, used by the booking pages (form encoded, needs a CSRF token)",
+		Tags:        []string{"legacy"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"application/x-www-form-urlencoded": {Schema: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"start":      {Type: "string", Format: "date"},
+					"end":        {Type: "string", Format: "date"},
+					"room_id":    {Type: "integer"},
+					"csrf_token": {Type: "string"},
+				},
+			}},
+		}},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Availability of the room", Content: openapi.JSON(openapi.Ref("LegacyAvailability"))},
+		},
+	})
+
+	return doc
+}
+
+// APIOpenAPI serves the OpenAPI document
+func (this *Repository) APIOpenAPI(w http.ResponseWriter, r *http.Request) {
+	helpers.WriteJSON(w, http.StatusOK, OpenAPIDocument())
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,6 +16,9 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/metrics"
 	"github.com/gummy789j/bookings/internal/models"
 	"github.com/gummy789j/bookings/internal/render"
 	"github.com/justinas/nosurf"
@@ -32,6 +36,7 @@ var functions = template.FuncMap{
 	"formatDate": render.FormatDate,
 	"iterate":    render.Iterate,
 	"add":        render.Add,
+	"static":     render.StaticPath,
 }
 
 func TestMain(m *testing.M) {
@@ -45,11 +50,8 @@ func TestMain(m *testing.M) {
 	gob.Register(map[string]int{})
 
 	app.InProduction = false
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	app.InfoLog = infoLog
-
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	app.ErrorLog = errorLog
+	app.Logger = logging.New(os.Stdout, app.InProduction, slog.LevelInfo)
+	app.Metrics = metrics.New()
 
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
@@ -64,6 +66,11 @@ func TestMain(m *testing.M) {
 
 	ListenForMail()
 
+	app.WebhookChan = make(chan models.WebhookEvent)
+	defer close(app.WebhookChan)
+
+	ListenForWebhooks()
+
 	tc, err := CreateTestTemplateCache()
 	if err != nil {
 		log.Fatal("cannot create template cache")
@@ -77,6 +84,8 @@ func TestMain(m *testing.M) {
 
 	render.NewRenderer(&app)
 
+	helpers.NewHelpers(&app)
+
 	os.Exit(m.Run())
 }
 
@@ -88,16 +97,28 @@ func ListenForMail() {
 	}()
 }
 
+func ListenForWebhooks() {
+	go func() {
+		for {
+			_ = <-app.WebhookChan
+		}
+	}()
+}
+
 func getRoutes() http.Handler {
 
 	mux := chi.NewRouter()
 
 	mux.Use(middleware.Recoverer)
+	mux.Use(app.Metrics.Middleware)
 
 	//mux.Use(WriteToConsole)
 	//mux.Use(NoSurf)
 	mux.Use(SessionLoad)
 
+	mux.NotFound(Repo.NotFound)
+	mux.MethodNotAllowed(Repo.MethodNotAllowed)
+
 	mux.Get("/", Repo.Home)
 	mux.Get("/about", Repo.About)
 	mux.Get("/search-availability", Repo.Availability)
@@ -113,14 +134,57 @@ func getRoutes() http.Handler {
 	mux.Get("/user/login", Repo.ShowLogin)
 	mux.Post("/user/login", Repo.PostShowLogin)
 	mux.Get("/admin/dashboard", Repo.AdminDashBoard)
+	mux.Get("/admin/dashboard/summary.json", Repo.AdminDashboardSummary)
+	mux.Get("/admin/dashboard/occupancy.json", Repo.AdminDashboardOccupancy)
+	mux.Get("/admin/today", Repo.AdminToday)
+	mux.Get("/admin/today/print", Repo.AdminTodayPrint)
+	mux.Post("/admin/today/{id}/check-in", Repo.AdminCheckIn)
+	mux.Post("/admin/today/{id}/check-out", Repo.AdminCheckOut)
 	mux.Get("/admin/reservations-new", Repo.AdminNewReservations)
 	mux.Get("/admin/reservations-all", Repo.AdminAllReservations)
+	mux.Get("/admin/reservations/export", Repo.AdminExportReservations)
+	mux.Get("/admin/import", Repo.AdminImport)
+	mux.Post("/admin/import", Repo.AdminPostImport)
+	mux.Post("/admin/import/commit", Repo.AdminCommitImport)
 	mux.Get("/admin/reservations-calendar", Repo.AdminReservationsCalendar)
-	mux.Post("/admin/reservations-calendar", Repo.AdminPostReservationsCalendar)
+	mux.Get("/admin/reservations-calendar/data", Repo.AdminCalendarData)
+	mux.Post("/admin/reservations-calendar/blocks", Repo.AdminCalendarBlock)
+	mux.Post("/admin/reservations-calendar/blocks/{id}/delete", Repo.AdminCalendarUnblock)
+	mux.Post("/admin/reservations-calendar/reservations/{id}/move", Repo.AdminCalendarMoveReservation)
 	mux.Get("/admin/reservations/{src}/{id}/show", Repo.AdminShowReservation)
 	mux.Post("/admin/reservations/{src}/{id}", Repo.AdminPostShowReservation)
-	mux.Get("/admin/process-reservations/{src}/{id}/do", Repo.AdminProcessReservation)
-	mux.Get("/admin/delete-reservations/{src}/{id}/do", Repo.AdminDeleteReservation)
+	mux.Post("/admin/process-reservations/{src}/{id}/do", Repo.AdminProcessReservation)
+	mux.Post("/admin/delete-reservations/{src}/{id}/do", Repo.AdminDeleteReservation)
+	mux.Get("/admin/trash", Repo.AdminTrash)
+	mux.Post("/admin/trash/{id}/restore", Repo.AdminRestoreReservation)
+	mux.Get("/admin/api-keys", Repo.AdminAPIKeys)
+	mux.Post("/admin/api-keys", Repo.AdminPostAPIKey)
+	mux.Get("/admin/api-keys/{id}/calls", Repo.AdminAPIKeyCalls)
+	mux.Post("/admin/api-keys/{id}/revoke", Repo.AdminRevokeAPIKey)
+	mux.Get("/rooms/{id}/calendar/{secret}.ics", Repo.RoomICalFeed)
+	mux.Get("/admin/rooms", Repo.AdminRooms)
+	mux.Post("/admin/rooms/{id}/rotate-ical-secret", Repo.AdminRotateRoomICalSecret)
+	mux.Get("/admin/ical-feeds", Repo.AdminICalFeeds)
+	mux.Post("/admin/ical-feeds", Repo.AdminPostICalFeed)
+	mux.Get("/admin/ical-feeds/{id}", Repo.AdminShowICalFeed)
+	mux.Post("/admin/ical-feeds/{id}/import", Repo.AdminImportICalFeed)
+	mux.Post("/admin/ical-feeds/{id}/delete", Repo.AdminDeleteICalFeed)
+	mux.Get("/admin/audit", Repo.AdminAuditLog)
+	mux.Get("/admin/webhooks", Repo.AdminWebhooks)
+	mux.Post("/admin/webhooks", Repo.AdminPostWebhook)
+	mux.Get("/admin/webhooks/{id}", Repo.AdminShowWebhook)
+	mux.Post("/admin/webhooks/{id}/delete", Repo.AdminDeleteWebhook)
+	mux.Post("/admin/webhooks/deliveries/{id}/redeliver", Repo.AdminRedeliverWebhook)
+
+	mux.Route("/api/v1", func(mux chi.Router) {
+		mux.NotFound(Repo.APINotFound)
+		mux.MethodNotAllowed(Repo.APIMethodNotAllowed)
+		mux.Get("/rooms", Repo.APIListRooms)
+		mux.Get("/availability", Repo.APIAvailability)
+		mux.Post("/reservations", Repo.APICreateReservation)
+		mux.Get("/reservations/{id}", Repo.APIGetReservation)
+		mux.Delete("/reservations/{id}", Repo.APICancelReservation)
+	})
 
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
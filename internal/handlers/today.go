@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+)
+
+// frontDeskDate reads the day to show from the form, adding an error for a bad value. Without one it is today.
+func frontDeskDate(form *forms.Forms, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if form.Get("date") == "" || !form.IsDate("date") {
+		return today
+	}
+
+	date, _ := time.Parse(apiDateLayout, form.Get("date"))
+	return date
+}
+
+// frontDesk gathers the arrivals, departures, guests in house and blocked rooms of a day
+func (this *Repository) frontDesk(date time.Time) (models.FrontDesk, error) {
+	var err error
+
+	fd := models.FrontDesk{Date: date}
+
+	if fd.Arrivals, err = this.DB.ReservationsArriving(date); err != nil {
+		return fd, err
+	}
+
+	if fd.Departures, err = this.DB.ReservationsDeparting(date); err != nil {
+		return fd, err
+	}
+
+	if fd.InHouse, err = this.DB.ReservationsInHouse(date); err != nil {
+		return fd, err
+	}
+
+	if fd.Blocks, err = this.DB.BlocksOn(date); err != nil {
+		return fd, err
+	}
+
+	return fd, nil
+}
+
+// renderFrontDesk shows the front desk view of the requested day with the given template
+func (this *Repository) renderFrontDesk(w http.ResponseWriter, r *http.Request, tmpl string) {
+
+	form := forms.New(r.URL.Query())
+	now := time.Now()
+	date := frontDeskDate(form, now)
+
+	fd, err := this.frontDesk(date)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["front_desk"] = fd
+
+	stringMap := make(map[string]string)
+	stringMap["date"] = date.Format(apiDateLayout)
+	stringMap["heading"] = date.Format("Monday, 2 January 2006")
+	stringMap["prev"] = date.AddDate(0, 0, -1).Format(apiDateLayout)
+	stringMap["next"] = date.AddDate(0, 0, 1).Format(apiDateLayout)
+	stringMap["today"] = frontDeskDate(forms.New(nil), now).Format(apiDateLayout)
+	stringMap["printed"] = now.Format("2006-01-02 15:04")
+
+	if err := render.Template(w, r, tmpl, &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		Form:      form,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminToday shows who arrives, who leaves, who is in house and which rooms are blocked on a day
+func (this *Repository) AdminToday(w http.ResponseWriter, r *http.Request) {
+	this.renderFrontDesk(w, r, "admin-today.page.tmpl")
+}
+
+// AdminTodayPrint shows the front desk view of a day on its own, for printing
+func (this *Repository) AdminTodayPrint(w http.ResponseWriter, r *http.Request) {
+	this.renderFrontDesk(w, r, "admin-today-print.page.tmpl")
+}
+
+// AdminCheckIn records that a guest has arrived
+func (this *Repository) AdminCheckIn(w http.ResponseWriter, r *http.Request) {
+	this.frontDeskAction(w, r, audit.ActionCheckIn, "Checked in", this.DB.CheckInReservation, func(res models.Reservation) string {
+		if res.CheckedIn() {
+			return "Guest is already checked in"
+		}
+		return ""
+	})
+}
+
+// AdminCheckOut records that a guest has left
+func (this *Repository) AdminCheckOut(w http.ResponseWriter, r *http.Request) {
+	this.frontDeskAction(w, r, audit.ActionCheckOut, "Checked out", this.DB.CheckOutReservation, func(res models.Reservation) string {
+		if !res.CheckedIn() {
+			return "Guest has not checked in"
+		}
+		if res.CheckedOut() {
+			return "Guest is already checked out"
+		}
+		return ""
+	})
+}
+
+// frontDeskAction applies a check in or check out to the reservation in the URL, unless refuse gives a reason
+// not to, and goes back to the day it was made from
+func (this *Repository) frontDeskAction(w http.ResponseWriter, r *http.Request, action, done string, apply func(id int) error, refuse func(models.Reservation) string) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	back := "/admin/today"
+	if form := forms.New(r.PostForm); form.Get("date") != "" && form.IsDate("date") {
+		back += "?date=" + form.Get("date")
+	}
+
+	res, err := this.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if reason := refuse(res); reason != "" {
+		this.App.Session.Put(r.Context(), "error", reason)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	err = apply(res.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	after := res
+	if action == audit.ActionCheckIn {
+		after.CheckedInAt = time.Now()
+	} else {
+		after.CheckedOutAt = time.Now()
+	}
+	this.recordAudit(r, action, audit.EntityReservation, res.ID, audit.Reservation(res), audit.Reservation(after))
+
+	this.App.Session.Put(r.Context(), "flash", strings.TrimSpace(done+" "+res.FirstName+" "+res.LastName))
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
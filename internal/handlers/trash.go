@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/audit"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+	"github.com/gummy789j/bookings/internal/repository"
+	"github.com/gummy789j/bookings/internal/webhooks"
+)
+
+// AdminTrash lists the deleted reservations that can still be restored
+func (this *Repository) AdminTrash(w http.ResponseWriter, r *http.Request) {
+
+	reservations, err := this.DB.DeletedReservations()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservations"] = reservations
+
+	intMap := make(map[string]int)
+	intMap["retention_days"] = int(this.App.TrashRetention / (24 * time.Hour))
+
+	if err := render.Template(w, r, "admin-trash.page.tmpl", &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminRestoreReservation takes a reservation out of the trash, as long as its room is still free for its dates
+func (this *Repository) AdminRestoreReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	res, err := this.DB.GetDeletedReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = this.DB.RestoreReservation(id)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		this.App.Session.Put(r.Context(), "error", "The room has been booked for those dates since, move the other stay first")
+		http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	after := res
+	after.DeletedAt = time.Time{}
+	this.recordAudit(r, audit.ActionRestore, audit.EntityReservation, id, audit.Reservation(res), audit.Reservation(after))
+	this.fireWebhook(webhooks.ReservationRestored, newReservationResponse(after))
+
+	this.App.Session.Put(r.Context(), "flash", "Reservation restored")
+	http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/gummy789j/bookings/internal/forms"
+	"github.com/gummy789j/bookings/internal/helpers"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
+	"github.com/gummy789j/bookings/internal/webhooks"
+)
+
+// blockPayload is an owner block as sent to webhooks
+type blockPayload struct {
+	ID     int    `json:"id,omitempty"`
+	RoomID int    `json:"room_id"`
+	Date   string `json:"date"`
+}
+
+// fireWebhook queues an event for the webhooks subscribed to it
+func (this *Repository) fireWebhook(event string, data interface{}) {
+
+	body, err := webhooks.NewPayload(event, data)
+	if err != nil {
+		this.App.Logger.Error("cannot build webhook payload", slog.String("event", event), logging.Err(err))
+		return
+	}
+
+	this.App.WebhookChan <- models.WebhookEvent{
+		Event:   event,
+		Payload: body,
+	}
+}
+
+// AdminWebhooks lists the webhook endpoints
+func (this *Repository) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	this.renderWebhooks(w, r, forms.New(nil))
+}
+
+func (this *Repository) renderWebhooks(w http.ResponseWriter, r *http.Request, form *forms.Forms) {
+
+	hooks, err := this.DB.AllWebhooks()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["webhooks"] = hooks
+	data["events"] = webhooks.Events
+
+	if err := render.Template(w, r, "admin-webhooks.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminPostWebhook adds a webhook endpoint
+func (this *Repository) AdminPostWebhook(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("url")
+
+	u, err := url.Parse(form.Get("url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		form.Errors.Add("url", "Must be an http or https URL")
+	}
+
+	var events []string
+	for _, e := range r.PostForm["events"] {
+		if !webhooks.IsEvent(e) {
+			form.Errors.Add("events", "Unknown event "+e)
+			continue
+		}
+		events = append(events, e)
+	}
+
+	if len(events) == 0 {
+		form.Errors.Add("events", "Choose at least one event")
+	}
+
+	if !form.Valid() {
+		this.renderWebhooks(w, r, form)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	_, err = this.DB.InsertWebhook(models.Webhook{
+		URL:    form.Get("url"),
+		Secret: secret,
+		Events: events,
+		Active: true,
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	this.App.Session.Put(r.Context(), "flash", "Webhook added")
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminShowWebhook shows a webhook endpoint with its delivery log
+func (this *Repository) AdminShowWebhook(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	hook, err := this.DB.GetWebhookByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	deliveries, err := this.DB.WebhookDeliveries(id, 100)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["webhook"] = hook
+	data["deliveries"] = deliveries
+
+	if err := render.Template(w, r, "admin-webhook-show.page.tmpl", &models.TemplateData{
+		Data: data,
+	}); err != nil {
+		helpers.ServerError(w, err)
+	}
+}
+
+// AdminDeleteWebhook removes a webhook endpoint
+func (this *Repository) AdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = this.DB.DeleteWebhook(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	this.App.Session.Put(r.Context(), "flash", "Webhook deleted")
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminRedeliverWebhook sends a logged delivery again, to the same webhook with the same payload
+func (this *Repository) AdminRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	d, err := this.DB.GetWebhookDeliveryByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	this.App.WebhookChan <- models.WebhookEvent{
+		Event:     d.Event,
+		Payload:   []byte(d.Payload),
+		WebhookID: d.WebhookID,
+	}
+
+	this.App.Session.Put(r.Context(), "flash", "Redelivery queued")
+	http.Redirect(w, r, "/admin/webhooks/"+strconv.Itoa(d.WebhookID), http.StatusSeeOther)
+}
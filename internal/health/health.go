@@ -0,0 +1,156 @@
+// Package health answers the probes of load balancers and orchestrators: whether the process is up, and whether
+// it is ready to serve because everything it depends on is
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/logging"
+)
+
+// CheckFunc reports why a dependency can't be used, or nil when it can. It should give up when ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs the readiness checks. A nil *Checker has no checks and is always ready.
+type Checker struct {
+	Timeout time.Duration
+	Logger  *slog.Logger
+
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+// New returns a checker giving each check up to timeout
+func New(timeout time.Duration, logger *slog.Logger) *Checker {
+	return &Checker{
+		Timeout: timeout,
+		Logger:  logger,
+	}
+}
+
+// Add adds a check, reported under name
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name, fn})
+}
+
+// ShutDown reports not ready from now on, so that load balancers stop sending requests while the server stops
+func (c *Checker) ShutDown() {
+	if c == nil {
+		return
+	}
+	c.shuttingDown.Store(true)
+}
+
+// CheckResult is how one check went
+type CheckResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_ms"`
+}
+
+// Report is the answer to a readiness probe
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+const (
+	statusOK           = "ok"
+	statusFailing      = "failing"
+	statusReady        = "ready"
+	statusNotReady     = "not ready"
+	statusShuttingDown = "shutting down"
+)
+
+// Check runs every check at once and reports whether all passed. Why a check failed is logged rather than
+// reported, since the report is public.
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: statusReady, Checks: []CheckResult{}}
+	if c == nil {
+		return report
+	}
+
+	if c.shuttingDown.Load() {
+		report.Status = statusShuttingDown
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	report.Checks = make([]CheckResult, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := ch.fn(ctx)
+
+			report.Checks[i] = CheckResult{
+				Name:    ch.name,
+				Status:  statusOK,
+				Latency: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			if err != nil {
+				report.Checks[i].Status = statusFailing
+				if c.Logger != nil {
+					c.Logger.WarnContext(ctx, "readiness check failed", slog.String("check", ch.name), logging.Err(err))
+				}
+			}
+		}(i, ch)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != statusOK {
+			report.Status = statusNotReady
+		}
+	}
+
+	return report
+}
+
+// Ready answers a readiness probe with the report, and 503 Service Unavailable unless every check passed
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != statusReady {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, report)
+}
+
+// Live answers a liveness probe, which only needs the process to be able to answer
+func Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": statusOK})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(out)
+}
@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/logging"
+)
+
+func probe(t *testing.T, h http.HandlerFunc) (int, Report) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON but got %q", ct)
+	}
+
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("cannot parse %q: %v", rr.Body.String(), err)
+	}
+
+	return rr.Code, report
+}
+
+func TestLive(t *testing.T) {
+	code, report := probe(t, Live)
+
+	if code != http.StatusOK || report.Status != "ok" {
+		t.Errorf("expected 200 ok but got %d %q", code, report.Status)
+	}
+}
+
+func TestReady(t *testing.T) {
+	c := New(time.Second, logging.Discard())
+	c.Add("database", func(ctx context.Context) error { return nil })
+	c.Add("mail", func(ctx context.Context) error { return nil })
+
+	code, report := probe(t, c.Ready)
+
+	if code != http.StatusOK || report.Status != "ready" {
+		t.Errorf("expected 200 ready but got %d %q", code, report.Status)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "database" || report.Checks[1].Name != "mail" {
+		t.Fatalf("expected the checks in the order added, got %+v", report.Checks)
+	}
+	for _, check := range report.Checks {
+		if check.Status != "ok" || check.Latency < 0 {
+			t.Errorf("unexpected result %+v", check)
+		}
+	}
+}
+
+func TestNotReady(t *testing.T) {
+	c := New(50*time.Millisecond, logging.Discard())
+	c.Add("database", func(ctx context.Context) error { return nil })
+	c.Add("mail", func(ctx context.Context) error { return errors.New("connection refused at 10.0.0.5") })
+	c.Add("templates", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := probe(t, c.Ready)
+
+	if code != http.StatusServiceUnavailable || report.Status != "not ready" {
+		t.Errorf("expected 503 not ready but got %d %q", code, report.Status)
+	}
+
+	want := map[string]string{"database": "ok", "mail": "failing", "templates": "failing"}
+	for _, check := range report.Checks {
+		if check.Status != want[check.Name] {
+			t.Errorf("expected %s to be %s, got %s", check.Name, want[check.Name], check.Status)
+		}
+	}
+	if report.Checks[2].Latency < 50 {
+		t.Errorf("expected the hung check to take the timeout, took %vms", report.Checks[2].Latency)
+	}
+}
+
+func TestNotReadyWhileShuttingDown(t *testing.T) {
+	c := New(time.Second, logging.Discard())
+	c.Add("database", func(ctx context.Context) error { return nil })
+
+	c.ShutDown()
+
+	code, report := probe(t, c.Ready)
+
+	if code != http.StatusServiceUnavailable || report.Status != "shutting down" {
+		t.Errorf("expected 503 shutting down but got %d %q", code, report.Status)
+	}
+
+	// the process itself is still up
+	if code, _ := probe(t, Live); code != http.StatusOK {
+		t.Errorf("expected the liveness probe to pass while shutting down, got %d", code)
+	}
+}
+
+func TestNilCheckerIsReady(t *testing.T) {
+	var c *Checker
+
+	c.ShutDown()
+
+	if code, _ := probe(t, c.Ready); code != http.StatusOK {
+		t.Errorf("expected 200 but got %d", code)
+	}
+}
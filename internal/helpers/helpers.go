@@ -1,11 +1,20 @@
 package helpers
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"strings"
 
 	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/render"
 )
 
 var app *config.AppConfig
@@ -14,19 +23,120 @@ func NewHelpers(a *config.AppConfig) {
 	app = a
 }
 
+// ClientError answers with a client error status, showing its page when there is one
 func ClientError(w http.ResponseWriter, status int) {
 
-	app.InfoLog.Println("Client error with status of", status)
-	http.Error(w, http.StatusText(status), status)
+	app.Logger.InfoContext(responseContext(w), "client error", slog.Int("status", status))
+	render.ErrorPage(w, status)
 }
 
+// ServerError logs the error with a stack trace and shows the 500 page
 func ServerError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.ErrorLog.Println(trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	logServerError(w, err)
+	render.ErrorPage(w, http.StatusInternalServerError)
+}
+
+// logServerError logs an error with the stack that led to it as a field of its own
+func logServerError(w http.ResponseWriter, err error) {
+	app.Logger.ErrorContext(responseContext(w), err.Error(), slog.String("stack", string(debug.Stack())))
+}
+
+// responseContext is a context carrying the ID of the request being answered. The helpers aren't given the
+// request, but the RequestID middleware has already put the ID in the response headers.
+func responseContext(w http.ResponseWriter) context.Context {
+	return logging.WithRequestID(context.Background(), w.Header().Get(logging.RequestIDHeader))
 }
 
 func IsAuthenticated(r *http.Request) bool {
 	exists := app.Session.Exists(r.Context(), "user_id")
 	return exists
 }
+
+type apiKeyContextKey struct{}
+
+// NewAPIKey generates a random API key token, the prefix shown to admins and the hash stored in the database
+func NewAPIKey() (token, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+
+	token = "bk_" + hex.EncodeToString(b)
+
+	return token, token[:11], HashAPIKey(token), nil
+}
+
+// HashAPIKey returns the hex encoded sha256 of an API key token
+func HashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BearerToken returns the token from an "Authorization: Bearer" header, if any
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+
+	return token, token != ""
+}
+
+// WithAPIKey stores the API key that authenticated a request in its context
+func WithAPIKey(ctx context.Context, key models.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the API key that authenticated a request, if any
+func APIKeyFromContext(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(models.APIKey)
+	return key, ok
+}
+
+// JSONError is the body returned by the JSON API when a request fails
+type JSONError struct {
+	Error JSONErrorDetail `json:"error"`
+}
+
+// JSONErrorDetail describes what went wrong, Fields holds per-field validation messages
+type JSONErrorDetail struct {
+	Status  int                 `json:"status"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+// WriteJSON writes data as an indented JSON response with the given status
+func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
+	out, err := json.MarshalIndent(data, "", "     ")
+	if err != nil {
+		ServerErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
+
+// ErrorJSON writes a JSON error body, fields holds per-field validation messages and may be nil
+func ErrorJSON(w http.ResponseWriter, status int, message string, fields map[string][]string) {
+	out, _ := json.MarshalIndent(JSONError{
+		Error: JSONErrorDetail{
+			Status:  status,
+			Message: message,
+			Fields:  fields,
+		},
+	}, "", "     ")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
+
+// ServerErrorJSON logs the error with a stack trace and writes a JSON 500 response
+func ServerErrorJSON(w http.ResponseWriter, err error) {
+	logServerError(w, err)
+	ErrorJSON(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+}
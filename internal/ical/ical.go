@@ -0,0 +1,264 @@
+// Package ical reads and writes the small subset of iCalendar (RFC 5545) used to
+// sync room availability with external booking sites: all-day busy events.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const dateLayout = "20060102"
+const stampLayout = "20060102T150405Z"
+
+// Event is a busy period. Start and End are dates, End is exclusive like a check out date.
+type Event struct {
+	UID     string
+	Start   time.Time
+	End     time.Time
+	Summary string
+
+	// Stamp is when the event was last changed
+	Stamp time.Time
+}
+
+// Calendar is a feed of events
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Marshal encodes the calendar as an .ics document
+func (c Calendar) Marshal() []byte {
+	var b bytes.Buffer
+
+	line := func(s string) {
+		b.WriteString(fold(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + c.ProdID)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:" + escape(c.Name))
+	}
+
+	for _, e := range c.Events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + e.Stamp.UTC().Format(stampLayout))
+		line("DTSTART;VALUE=DATE:" + e.Start.Format(dateLayout))
+		line("DTEND;VALUE=DATE:" + e.End.Format(dateLayout))
+		line("SUMMARY:" + escape(e.Summary))
+		line("TRANSP:OPAQUE")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	return b.Bytes()
+}
+
+// escape escapes a TEXT value
+func escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// fold splits a content line into lines of at most 75 octets, continuation lines start with a space
+func fold(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+
+	return b.String()
+}
+
+// Parse reads the events of an .ics document. Times are reduced to the days they touch, so
+// an event ending at 11:00 on a day still takes that day. Cancelled events are skipped, and
+// events without a UID get one made from their dates.
+func Parse(r io.Reader) ([]Event, error) {
+
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var e *Event
+	var cancelled, sawCalendar, sawEnd bool
+
+	for n, l := range lines {
+		name, params, value := splitLine(l)
+
+		switch {
+		case name == "BEGIN" && value == "VCALENDAR":
+			sawCalendar = true
+
+		case name == "END" && value == "VCALENDAR":
+			sawEnd = true
+
+		case name == "BEGIN" && value == "VEVENT":
+			e = &Event{}
+			cancelled = false
+
+		case name == "END" && value == "VEVENT":
+			if e == nil {
+				return nil, fmt.Errorf("line %d: END:VEVENT without BEGIN", n+1)
+			}
+			if e.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event without DTSTART", n+1)
+			}
+			if e.End.IsZero() || !e.End.After(e.Start) {
+				e.End = e.Start.AddDate(0, 0, 1)
+			}
+			if e.UID == "" {
+				e.UID = e.Start.Format(dateLayout) + "-" + e.End.Format(dateLayout)
+			}
+			if !cancelled {
+				events = append(events, *e)
+			}
+			e = nil
+
+		case e == nil:
+			// calendar properties and other components are ignored
+
+		case name == "UID":
+			e.UID = value
+
+		case name == "SUMMARY":
+			e.Summary = unescape(value)
+
+		case name == "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+
+		case name == "DTSTAMP":
+			e.Stamp, _ = time.Parse(stampLayout, value)
+
+		case name == "DTSTART":
+			t, _, err := parseDate(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n+1, err)
+			}
+			e.Start = day(t)
+
+		case name == "DTEND":
+			t, hasTime, err := parseDate(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n+1, err)
+			}
+			e.End = day(t)
+			// an end during the day still takes that night, going by the wall clock of its own time zone
+			if hasTime && (t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0) {
+				e.End = e.End.AddDate(0, 0, 1)
+			}
+		}
+	}
+
+	if !sawCalendar {
+		return nil, errors.New("not an iCalendar document")
+	}
+
+	// a document cut short would drop the events after the cut
+	if !sawEnd {
+		return nil, errors.New("calendar is incomplete, END:VCALENDAR is missing")
+	}
+
+	return events, nil
+}
+
+// unfold reads content lines, joining folded continuation lines
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		l := strings.TrimRight(scanner.Text(), "\r")
+		if l == "" {
+			continue
+		}
+		if (l[0] == ' ' || l[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, scanner.Err()
+}
+
+// splitLine splits "NAME;PARAM=X:value" into its name, params and value
+func splitLine(l string) (string, map[string]string, string) {
+	i := strings.Index(l, ":")
+	if i < 0 {
+		return strings.ToUpper(l), nil, ""
+	}
+
+	head, value := l[:i], l[i+1:]
+	parts := strings.Split(head, ";")
+
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseDate parses a DATE or DATE-TIME value, it reports whether the value had a time
+func parseDate(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tz, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(stampLayout, value)
+		return t, true, err
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, true, err
+}
+
+// day is the date of t, at midnight UTC
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// unescape reverses escape
+func unescape(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return r.Replace(s)
+}
@@ -0,0 +1,150 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	stamp := time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)
+
+	cal := Calendar{
+		ProdID: "-//Bookings//Rooms//EN",
+		Name:   "Major's Suite",
+		Events: []Event{
+			{
+				UID:     "restriction-7@bookings",
+				Start:   time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC),
+				End:     time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC),
+				Summary: "Reserved, not available",
+				Stamp:   stamp,
+			},
+		},
+	}
+
+	out := string(cal.Marshal())
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Major's Suite\r\n",
+		"UID:restriction-7@bookings\r\n",
+		"DTSTAMP:20261001T123000Z\r\n",
+		"DTSTART;VALUE=DATE:20261102\r\n",
+		"DTEND;VALUE=DATE:20261105\r\n",
+		"SUMMARY:Reserved\\, not available\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got\n%s", want, out)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	long := "SUMMARY:" + strings.Repeat("x", 200)
+
+	for _, l := range strings.Split(fold(long), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("folded line is %d octets long", len(l))
+		}
+	}
+
+	if strings.Replace(fold(long), "\r\n ", "", -1) != long {
+		t.Error("unfolding did not give back the original line")
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Example//EN\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:abc@example.com\r\n" +
+		"DTSTART;VALUE=DATE:20261102\r\n" +
+		"DTEND;VALUE=DATE:20261105\r\n" +
+		"SUMMARY:Booked\\, via\r\n" +
+		"  channel\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:timed@example.com\r\n" +
+		"DTSTART:20261110T150000Z\r\n" +
+		"DTEND:20261112T110000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:paris@example.com\r\n" +
+		"DTSTART;TZID=Europe/Paris:20261114T150000\r\n" +
+		"DTEND;TZID=Europe/Paris:20261116T000000\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:gone@example.com\r\n" +
+		"STATUS:CANCELLED\r\n" +
+		"DTSTART;VALUE=DATE:20261120\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20261201\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	events, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events but got %d", len(events))
+	}
+
+	date := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		uid     string
+		start   time.Time
+		end     time.Time
+		summary string
+	}{
+		{"abc@example.com", date(11, 2), date(11, 5), "Booked, via channel"},
+		{"timed@example.com", date(11, 10), date(11, 13), ""},
+		{"paris@example.com", date(11, 14), date(11, 16), ""},
+		{"20261201-20261202", date(12, 1), date(12, 2), ""},
+	}
+
+	for i, e := range tests {
+		got := events[i]
+		if got.UID != e.uid || !got.Start.Equal(e.start) || !got.End.Equal(e.end) || got.Summary != e.summary {
+			t.Errorf("event %d: got %+v, expected %+v", i, got, e)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	cal := Calendar{
+		ProdID: "-//Bookings//Rooms//EN",
+		Events: []Event{
+			{UID: "1@bookings", Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), Summary: strings.Repeat("long; summary ", 10)},
+		},
+	}
+
+	events, err := Parse(strings.NewReader(string(cal.Marshal())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].UID != "1@bookings" || events[0].Summary != cal.Events[0].Summary || !events[0].End.Equal(cal.Events[0].End) {
+		t.Errorf("round trip gave %+v", events)
+	}
+}
+
+func TestParseRejectsNonCalendar(t *testing.T) {
+	if _, err := Parse(strings.NewReader("<html></html>")); err == nil {
+		t.Error("expected an error for a document that is not a calendar")
+	}
+}
+
+func TestParseRejectsTruncatedCalendar(t *testing.T) {
+	doc := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;VALUE=DATE:20261102\r\nEND:VEVENT\r\nBEGIN:VEV"
+
+	if _, err := Parse(strings.NewReader(doc)); err == nil {
+		t.Error("expected an error for a calendar without END:VCALENDAR")
+	}
+}
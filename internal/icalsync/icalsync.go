@@ -0,0 +1,200 @@
+// Package icalsync imports the iCal feeds of external booking sites as room blocks.
+package icalsync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/ical"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository"
+)
+
+// ExternalBooking is the restriction id of blocks imported from a feed
+const ExternalBooking = 3
+
+// maxFeedSize is the largest feed accepted. A larger one fails the import rather than being cut short, which
+// would remove the blocks of every event past the cut.
+const maxFeedSize = 5 << 20
+
+// Importer keeps the blocks of each external calendar in step with its feed
+type Importer struct {
+	DB     repository.DatabaseRepo
+	Client *http.Client
+	Logger *slog.Logger
+}
+
+// NewImporter returns an importer with a default HTTP client
+func NewImporter(db repository.DatabaseRepo, logger *slog.Logger) *Importer {
+	return &Importer{
+		DB:     db,
+		Client: &http.Client{Timeout: 30 * time.Second},
+		Logger: logger,
+	}
+}
+
+// Start imports every feed now and then once per interval, until stop is closed
+func (im *Importer) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		im.ImportAll()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ImportAll imports every registered feed, one after the other
+func (im *Importer) ImportAll() {
+	feeds, err := im.DB.AllICalFeeds()
+	if err != nil {
+		im.Logger.Error("cannot list iCal feeds", logging.Err(err))
+		return
+	}
+
+	for _, feed := range feeds {
+		im.Import(feed)
+	}
+}
+
+// Import fetches one feed and adds, moves and removes its blocks to match it. The run is
+// logged whether it succeeds or not; when the feed can't be read the blocks are left alone.
+func (im *Importer) Import(feed models.ICalFeed) models.ICalImportRun {
+
+	run := models.ICalImportRun{ICalFeedID: feed.ID}
+
+	logger := im.Logger.With(slog.Int("feed_id", feed.ID), slog.String("feed", feed.Name))
+
+	if err := im.sync(feed, &run); err != nil {
+		run.Error = err.Error()
+		logger.Error("iCal feed not imported", logging.Err(err))
+	} else {
+		logger.Info("iCal feed imported", slog.Int("added", run.Added), slog.Int("updated", run.Updated),
+			slog.Int("removed", run.Removed), slog.Int("conflicts", run.Conflicts))
+	}
+
+	if err := im.DB.InsertICalImportRun(run); err != nil {
+		logger.Error("cannot record iCal import", logging.Err(err))
+	}
+
+	return run
+}
+
+func (im *Importer) sync(feed models.ICalFeed, run *models.ICalImportRun) error {
+
+	events, err := im.fetch(feed.URL)
+	if err != nil {
+		return err
+	}
+
+	existing, err := im.DB.ICalFeedBlocks(feed.ID)
+	if err != nil {
+		return err
+	}
+
+	byUID := make(map[string]models.RoomRestriction, len(existing))
+	for _, r := range existing {
+		byUID[r.ExternalUID] = r
+	}
+
+	var add, update []models.RoomRestriction
+	var remove []int
+
+	seen := make(map[string]bool, len(events))
+
+	for _, e := range events {
+		if seen[e.UID] {
+			continue
+		}
+		seen[e.UID] = true
+
+		r, ok := byUID[e.UID]
+		if !ok {
+			add = append(add, models.RoomRestriction{
+				StartDate:     e.Start,
+				EndDate:       e.End,
+				RoomID:        feed.RoomID,
+				RestrictionID: ExternalBooking,
+				ICalFeedID:    feed.ID,
+				ExternalUID:   e.UID,
+			})
+			continue
+		}
+
+		if !sameDay(r.StartDate, e.Start) || !sameDay(r.EndDate, e.End) {
+			r.StartDate = e.Start
+			r.EndDate = e.End
+			update = append(update, r)
+		}
+	}
+
+	for _, r := range existing {
+		if !seen[r.ExternalUID] {
+			remove = append(remove, r.ID)
+		}
+	}
+
+	if len(add)+len(update)+len(remove) > 0 {
+		if err := im.DB.SaveICalFeedBlocks(feed.ID, add, update, remove); err != nil {
+			return err
+		}
+	}
+
+	run.Added = len(add)
+	run.Updated = len(update)
+	run.Removed = len(remove)
+
+	conflicts, err := im.DB.ICalConflicts(feed.ID)
+	if err != nil {
+		return err
+	}
+	run.Conflicts = len(conflicts)
+
+	return nil
+}
+
+// fetch downloads and parses a feed
+func (im *Importer) fetch(url string) ([]ical.Event, error) {
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+	req.Header.Set("User-Agent", "bookings-ical/1.0")
+
+	resp, err := im.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > maxFeedSize {
+		return nil, fmt.Errorf("feed is larger than %d MiB", maxFeedSize>>20)
+	}
+
+	return ical.Parse(bytes.NewReader(body))
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
@@ -0,0 +1,183 @@
+package icalsync
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository"
+	"github.com/gummy789j/bookings/internal/repository/dbrepo"
+)
+
+// memoryRepo keeps the blocks of one feed in memory, every other call goes to the test repo
+type memoryRepo struct {
+	repository.DatabaseRepo
+
+	blocks []models.RoomRestriction
+	nextID int
+	runs   []models.ICalImportRun
+}
+
+func newMemoryRepo() *memoryRepo {
+	var app config.AppConfig
+	return &memoryRepo{DatabaseRepo: dbrepo.NewTestRepo(&app), nextID: 1}
+}
+
+func (m *memoryRepo) ICalFeedBlocks(feedID int) ([]models.RoomRestriction, error) {
+	return append([]models.RoomRestriction(nil), m.blocks...), nil
+}
+
+func (m *memoryRepo) SaveICalFeedBlocks(feedID int, add, update []models.RoomRestriction, removeIDs []int) error {
+	for _, r := range add {
+		r.ID = m.nextID
+		m.nextID++
+		m.blocks = append(m.blocks, r)
+	}
+
+	for _, u := range update {
+		for i := range m.blocks {
+			if m.blocks[i].ID == u.ID {
+				m.blocks[i] = u
+			}
+		}
+	}
+
+	for _, id := range removeIDs {
+		for i := range m.blocks {
+			if m.blocks[i].ID == id {
+				m.blocks = append(m.blocks[:i], m.blocks[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *memoryRepo) InsertICalImportRun(run models.ICalImportRun) error {
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+func event(uid, start, end string) string {
+	return fmt.Sprintf("BEGIN:VEVENT\r\nUID:%s\r\nDTSTART;VALUE=DATE:%s\r\nDTEND;VALUE=DATE:%s\r\nSUMMARY:Reserved\r\nEND:VEVENT\r\n", uid, start, end)
+}
+
+func TestImport(t *testing.T) {
+	feedBody := ""
+
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"+feedBody+"END:VCALENDAR\r\n")
+	}))
+	defer channel.Close()
+
+	repo := newMemoryRepo()
+	im := NewImporter(repo, logging.Discard())
+
+	feed := models.ICalFeed{ID: 1, RoomID: 2, Name: "Channel", URL: channel.URL}
+
+	// first import adds every event
+	feedBody = event("a", "20261102", "20261105") + event("b", "20261110", "20261112")
+
+	run := im.Import(feed)
+	if run.Error != "" || run.Added != 2 || run.Updated != 0 || run.Removed != 0 {
+		t.Fatalf("first import: unexpected run %+v", run)
+	}
+
+	for _, b := range repo.blocks {
+		if b.RestrictionID != ExternalBooking || b.RoomID != 2 || b.ICalFeedID != 1 {
+			t.Errorf("unexpected block %+v", b)
+		}
+	}
+
+	// nothing changed
+	run = im.Import(feed)
+	if run.Added+run.Updated+run.Removed != 0 {
+		t.Errorf("second import: expected no changes, got %+v", run)
+	}
+
+	// b moved, a was cancelled and c is new
+	feedBody = event("b", "20261111", "20261113") + event("c", "20261201", "20261203")
+
+	run = im.Import(feed)
+	if run.Added != 1 || run.Updated != 1 || run.Removed != 1 {
+		t.Errorf("third import: unexpected run %+v", run)
+	}
+
+	if len(repo.blocks) != 2 {
+		t.Fatalf("expected 2 blocks but got %d", len(repo.blocks))
+	}
+
+	want := map[string]time.Time{
+		"b": time.Date(2026, 11, 11, 0, 0, 0, 0, time.UTC),
+		"c": time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, b := range repo.blocks {
+		if !b.StartDate.Equal(want[b.ExternalUID]) {
+			t.Errorf("block %s starts %s, expected %s", b.ExternalUID, b.StartDate, want[b.ExternalUID])
+		}
+	}
+
+	if len(repo.runs) != 3 {
+		t.Errorf("expected 3 logged runs but got %d", len(repo.runs))
+	}
+}
+
+func TestImportKeepsBlocksWhenFeedFails(t *testing.T) {
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer channel.Close()
+
+	repo := newMemoryRepo()
+	repo.blocks = []models.RoomRestriction{{ID: 9, RoomID: 2, RestrictionID: ExternalBooking, ICalFeedID: 1, ExternalUID: "a"}}
+
+	im := NewImporter(repo, logging.Discard())
+
+	run := im.Import(models.ICalFeed{ID: 1, RoomID: 2, URL: channel.URL})
+
+	if run.Error == "" {
+		t.Error("expected the run to record an error")
+	}
+
+	if len(repo.blocks) != 1 {
+		t.Errorf("expected the existing block to be kept, got %d blocks", len(repo.blocks))
+	}
+
+	if len(repo.runs) != 1 || repo.runs[0].Error == "" {
+		t.Errorf("expected the failed run to be logged, got %+v", repo.runs)
+	}
+}
+
+func TestImportKeepsBlocksWhenFeedTooLarge(t *testing.T) {
+	// one event at the start, then more than the limit of properties that are ignored
+	filler := strings.Repeat("X-FILLER:"+strings.Repeat("x", 1000)+"\r\n", maxFeedSize/1000)
+
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"+event("a", "20261102", "20261105")+filler+event("b", "20261110", "20261112")+"END:VCALENDAR\r\n")
+	}))
+	defer channel.Close()
+
+	repo := newMemoryRepo()
+	repo.blocks = []models.RoomRestriction{
+		{ID: 8, RoomID: 2, RestrictionID: ExternalBooking, ICalFeedID: 1, ExternalUID: "a"},
+		{ID: 9, RoomID: 2, RestrictionID: ExternalBooking, ICalFeedID: 1, ExternalUID: "b"},
+	}
+
+	run := NewImporter(repo, logging.Discard()).Import(models.ICalFeed{ID: 1, RoomID: 2, URL: channel.URL})
+
+	if !strings.Contains(run.Error, "larger than") {
+		t.Errorf("expected the run to fail for the size, got %q", run.Error)
+	}
+
+	if len(repo.blocks) != 2 {
+		t.Errorf("expected the existing blocks to be kept, got %d blocks", len(repo.blocks))
+	}
+}
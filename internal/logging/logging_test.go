@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewWritesJSONInProduction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, true, slog.LevelInfo)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.ErrorContext(ctx, "failed", Err(errors.New("boom")))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a JSON record, got %q: %v", buf.String(), err)
+	}
+
+	for key, want := range map[string]string{"msg": "failed", "level": "ERROR", "request_id": "abc123", "error": "boom"} {
+		if record[key] != want {
+			t.Errorf("expected %s to be %q, got %v", key, want, record[key])
+		}
+	}
+}
+
+func TestNewWritesTextOtherwise(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, false, slog.LevelWarn).With(slog.String("feed", "airbnb"))
+
+	logger.Info("dropped")
+	logger.WarnContext(WithRequestID(context.Background(), "abc123"), "kept")
+
+	out := buf.String()
+	if strings.Contains(out, "dropped") {
+		t.Errorf("expected records below the level to be dropped, got %q", out)
+	}
+	for _, want := range []string{"msg=kept", "feed=airbnb", "request_id=abc123"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestNoRequestIDWithoutOne(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, false, slog.LevelInfo).Info("started")
+
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("expected no request_id, got %q", buf.String())
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	id := NewRequestID()
+	if len(id) != 16 || !ValidRequestID(id) {
+		t.Errorf("expected a valid 16 character ID, got %q", id)
+	}
+	if NewRequestID() == id {
+		t.Error("expected request IDs to differ")
+	}
+
+	var tests = []struct {
+		id    string
+		valid bool
+	}{
+		{"f3a9c2", true},
+		{"req-1_2.3", true},
+		{"", false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", 65), false},
+	}
+
+	for _, e := range tests {
+		if ValidRequestID(e.id) != e.valid {
+			t.Errorf("for %q, expected valid to be %v", e.id, e.valid)
+		}
+	}
+}
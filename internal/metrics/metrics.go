@@ -0,0 +1,158 @@
+// Package metrics counts what the application does, for Prometheus to scrape
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric
+const namespace = "bookings"
+
+// unmatchedRoute is the route of requests no route matched, so that scanners can't create a series per path
+const unmatchedRoute = "unmatched"
+
+// Metrics are the application's counters, in a registry of their own. A nil *Metrics counts nothing, which
+// suits commands and tests that don't need them.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	duration        *prometheus.HistogramVec
+	mailFailures    prometheus.Counter
+	loginFailures   prometheus.Counter
+	bookingsCreated *prometheus.CounterVec
+}
+
+// New registers the application's metrics along with the Go runtime and process ones
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests answered, by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "How long HTTP requests took to answer, by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		mailFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mail_send_failures_total",
+			Help:      "Emails that could not be sent.",
+		}),
+		loginFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Logins refused for a wrong email or password.",
+		}),
+		bookingsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bookings_created_total",
+			Help:      "Reservations made, by where they were made from.",
+		}, []string{"source"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.mailFailures,
+		m.loginFailures,
+		m.bookingsCreated,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// Middleware counts and times every request by the chi route pattern it matched, such as /choose-room/{id},
+// rather than by its path
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		// the pattern is only complete once the request has been routed
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		m.duration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// WatchDB reports the connection pool statistics of db
+func (m *Metrics) WatchDB(db *sql.DB) {
+	if m == nil {
+		return
+	}
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// WatchMailQueue reports how many emails are waiting to be sent, as counted by depth
+func (m *Metrics) WatchMailQueue(depth func() int) {
+	if m == nil {
+		return
+	}
+	m.Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mail_queue_depth",
+		Help:      "Emails waiting to be sent.",
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
+
+// MailFailed counts an email that could not be sent
+func (m *Metrics) MailFailed() {
+	if m == nil {
+		return
+	}
+	m.mailFailures.Inc()
+}
+
+// LoginFailed counts a refused login
+func (m *Metrics) LoginFailed() {
+	if m == nil {
+		return
+	}
+	m.loginFailures.Inc()
+}
+
+// BookingCreated counts a reservation made from source, such as web or api
+func (m *Metrics) BookingCreated(source string) {
+	if m == nil {
+		return
+	}
+	m.bookingsCreated.WithLabelValues(source).Inc()
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+// scrape returns the metrics as Prometheus would read them
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from the metrics handler but got %d", rr.Code)
+	}
+
+	return rr.Body.String()
+}
+
+func expectLines(t *testing.T, body string, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected the line %q in the metrics", line)
+		}
+	}
+}
+
+func TestMiddlewareCountsByRoutePattern(t *testing.T) {
+	m := New()
+
+	mux := chi.NewRouter()
+	mux.Use(m.Middleware)
+	mux.Get("/choose-room/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Route("/api/v1", func(mux chi.Router) {
+		mux.Post("/reservations", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+	})
+
+	for _, e := range []struct{ method, path string }{
+		{"GET", "/choose-room/1"},
+		{"GET", "/choose-room/2"},
+		{"POST", "/api/v1/reservations"},
+		{"GET", "/wp-login.php"},
+	} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(e.method, e.path, nil))
+	}
+
+	body := scrape(t, m)
+
+	expectLines(t, body,
+		`bookings_http_requests_total{method="GET",route="/choose-room/{id}",status="200"} 2`,
+		`bookings_http_requests_total{method="POST",route="/api/v1/reservations",status="201"} 1`,
+		`bookings_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`bookings_http_request_duration_seconds_count{method="GET",route="/choose-room/{id}"} 2`,
+	)
+
+	if strings.Contains(body, "/choose-room/1") || strings.Contains(body, "wp-login") {
+		t.Error("expected requests to be counted by route pattern, not path")
+	}
+}
+
+func TestCounters(t *testing.T) {
+	m := New()
+
+	m.LoginFailed()
+	m.LoginFailed()
+	m.MailFailed()
+	m.BookingCreated("web")
+	m.BookingCreated("api")
+	m.BookingCreated("web")
+
+	queued := 3
+	m.WatchMailQueue(func() int { return queued })
+
+	expectLines(t, scrape(t, m),
+		`bookings_login_failures_total 2`,
+		`bookings_mail_send_failures_total 1`,
+		`bookings_bookings_created_total{source="web"} 2`,
+		`bookings_bookings_created_total{source="api"} 1`,
+		`bookings_mail_queue_depth 3`,
+	)
+}
+
+func TestWatchDB(t *testing.T) {
+	m := New()
+
+	// opening a pool doesn't connect, and the statistics don't need a connection
+	db, err := sql.Open("pgx", "host=localhost dbname=bookings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(10)
+	m.WatchDB(db)
+
+	expectLines(t, scrape(t, m),
+		`go_sql_max_open_connections{db_name="bookings"} 10`,
+		`go_sql_open_connections{db_name="bookings"} 0`,
+	)
+}
+
+func TestNilMetricsCountNothing(t *testing.T) {
+	var m *Metrics
+
+	m.LoginFailed()
+	m.MailFailed()
+	m.BookingCreated("web")
+	m.WatchMailQueue(func() int { return 0 })
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if h := m.Middleware(ok); h == nil {
+		t.Error("expected the handler back")
+	}
+}
@@ -0,0 +1,392 @@
+// Package migrate applies SQL migrations to the database and records them in the schema_migrations table
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Migration is one schema change, with the SQL to make it and to undo it
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the SQL of the change, so that editing an applied migration can be noticed
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// String is the migration as its file names start
+func (m Migration) String() string {
+	return m.Version + "_" + m.Name
+}
+
+var fileName = regexp.MustCompile(`^(\d{14})_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the top directory of fsys, in version order. Every migration needs both an
+// up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*Migration)
+	seen := make(map[string]bool)
+
+	for _, f := range files {
+		match := fileName.FindStringSubmatch(path.Base(f))
+		if match == nil {
+			return nil, fmt.Errorf("%s is not named <version>_<name>.up.sql or <version>_<name>.down.sql", f)
+		}
+
+		version, name, direction := match[1], match[2], match[3]
+
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("version %s is used by both %s and %s", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+		seen[version+direction] = true
+	}
+
+	var migrations []Migration
+	for version, m := range byVersion {
+		if !seen[version+"up"] || !seen[version+"down"] {
+			return nil, fmt.Errorf("%s needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Status is whether a migration has been applied. Modified means its file changed after it was applied and
+// Unknown that the database has it but the files don't.
+type Status struct {
+	Migration
+	AppliedAt time.Time
+	Modified  bool
+	Unknown   bool
+}
+
+// Applied reports whether the migration is in the database
+func (s Status) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// record is a row of schema_migrations
+type record struct {
+	version   string
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// lockID keys the advisory lock that keeps two processes from migrating at once
+const lockID = 7283940152
+
+// Migrator applies a set of migrations to a database
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a migrator for the migrations in fsys
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Up applies every pending migration in version order, each in its own transaction, and returns the ones applied.
+// It refuses to start when an applied migration has been edited since.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.Migrations {
+			if rec, ok := applied[mig.Version]; ok && rec.checksum != "" && rec.checksum != mig.Checksum() {
+				return fmt.Errorf("migration %s has changed since it was applied", mig)
+			}
+		}
+
+		for _, mig := range m.Migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `insert into schema_migrations (version, name, checksum, applied_at) values ($1, $2, $3, $4)`,
+					mig.Version, mig.Name, mig.Checksum(), time.Now())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s failed: %v", mig, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and returns the ones rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		var versions []string
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+
+		known := make(map[string]Migration)
+		for _, mig := range m.Migrations {
+			known[mig.Version] = mig
+		}
+
+		for i := 0; i < steps && i < len(versions); i++ {
+			mig, ok := known[versions[i]]
+			if !ok {
+				return fmt.Errorf("migration %s_%s is applied but has no files to roll it back with", versions[i], applied[versions[i]].name)
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `delete from schema_migrations where version = $1`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rolling back %s failed: %v", mig, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every migration with whether it is applied, followed by any applied migration the files lack
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.Migrations {
+			s := Status{Migration: mig}
+			if rec, ok := applied[mig.Version]; ok {
+				s.AppliedAt = rec.appliedAt
+				s.Modified = rec.checksum != "" && rec.checksum != mig.Checksum()
+				delete(applied, mig.Version)
+			}
+			statuses = append(statuses, s)
+		}
+
+		for _, rec := range applied {
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: rec.version, Name: rec.name},
+				AppliedAt: rec.appliedAt,
+				Unknown:   true,
+			})
+		}
+
+		sort.SliceStable(statuses, func(i, j int) bool {
+			return statuses[i].Version < statuses[j].Version
+		})
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Pending counts the migrations not applied yet
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, s := range statuses {
+		if !s.Applied() {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// locked runs fn on one connection holding the migration lock, after making sure schema_migrations exists
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `select pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(context.Background(), `select pg_advisory_unlock($1)`, lockID)
+
+	if err := m.createTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// createTable creates schema_migrations. A database migrated with soda before has its schema_migration
+// table, so the versions listed there are taken over as applied.
+func (m *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `create table if not exists schema_migrations (
+		version varchar(14) primary key,
+		name varchar(255) not null,
+		checksum varchar(64) not null,
+		applied_at timestamp not null
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	var soda sql.NullString
+
+	err = conn.QueryRowContext(ctx, `select (select count(*) from schema_migrations), to_regclass('schema_migration')::text`).Scan(&count, &soda)
+	if err != nil || count > 0 || !soda.Valid {
+		return err
+	}
+
+	rows, err := conn.QueryContext(ctx, `select version from schema_migration`)
+	if err != nil {
+		return err
+	}
+
+	adopted := make(map[string]bool)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return err
+		}
+		adopted[v] = true
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return inTx(ctx, conn, func(tx *sql.Tx) error {
+		for _, mig := range m.Migrations {
+			if !adopted[mig.Version] {
+				continue
+			}
+			// soda ran the fizz files these replaced, so no checksum is recorded for them
+			_, err := tx.ExecContext(ctx, `insert into schema_migrations (version, name, checksum, applied_at) values ($1, $2, '', $3)`,
+				mig.Version, mig.Name, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applied reads schema_migrations by version
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[string]record, error) {
+	rows, err := conn.QueryContext(ctx, `select version, name, checksum, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[string]record)
+	for rows.Next() {
+		var rec record
+		if err := rows.Scan(&rec.version, &rec.name, &rec.checksum, &rec.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[rec.version] = rec
+	}
+
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gummy789j/bookings/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20210913172435_create_rooms_table.up.sql":   {Data: []byte("create table rooms ();")},
+		"20210913172435_create_rooms_table.down.sql": {Data: []byte("drop table rooms;")},
+		"20210913162544_create_user_table.up.sql":    {Data: []byte("create table users ();")},
+		"20210913162544_create_user_table.down.sql":  {Data: []byte("drop table users;")},
+	}
+
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d migrations, wanted 2", len(got))
+	}
+
+	if got[0].String() != "20210913162544_create_user_table" || got[1].String() != "20210913172435_create_rooms_table" {
+		t.Errorf("migrations out of order: %s, %s", got[0], got[1])
+	}
+
+	if got[1].Up != "create table rooms ();" || got[1].Down != "drop table rooms;" {
+		t.Errorf("unexpected SQL for %s: %+v", got[1], got[1])
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"missing down", fstest.MapFS{
+			"20210913162544_create_user_table.up.sql": {},
+		}, "needs both an up and a down file"},
+		{"bad name", fstest.MapFS{
+			"create_user_table.sql": {},
+		}, "is not named"},
+		{"shared version", fstest.MapFS{
+			"20210913162544_create_user_table.up.sql":  {},
+			"20210913162544_create_room_table.down.sql": {},
+		}, "is used by both"},
+	}
+
+	for _, e := range tests {
+		_, err := Load(e.files)
+		if err == nil || !strings.Contains(err.Error(), e.want) {
+			t.Errorf("%s: got error %v, wanted one containing %q", e.name, err, e.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	a := Migration{Up: "create table rooms ();"}
+	b := Migration{Up: "create table rooms (id int);"}
+
+	if a.Checksum() == b.Checksum() {
+		t.Error("expected different SQL to give different checksums")
+	}
+
+	if a.Checksum() != (Migration{Up: a.Up, Down: "changed"}).Checksum() {
+		t.Error("expected the checksum to depend on the up SQL only")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for _, m := range all {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("%s has an empty up or down file", m)
+		}
+	}
+}
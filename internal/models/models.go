@@ -25,10 +25,11 @@ type User struct {
 
 // Room is room model
 type Room struct {
-	ID        int
-	RoomName  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         int
+	RoomName   string
+	ICalSecret string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 // Restriction is restriction model
@@ -41,18 +42,36 @@ type Restriction struct {
 
 // Reservation is reservation model
 type Reservation struct {
-	ID        int
-	FirstName string
-	LastName  string
-	Email     string
-	Phone     string
-	StartDate time.Time
-	EndDate   time.Time
-	RoomID    int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Processed int
-	Room      Room
+	ID           int
+	FirstName    string
+	LastName     string
+	Email        string
+	Phone        string
+	StartDate    time.Time
+	EndDate      time.Time
+	RoomID       int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Processed    int
+	CheckedInAt  time.Time
+	CheckedOutAt time.Time
+	DeletedAt    time.Time
+	Room         Room
+}
+
+// CheckedIn reports whether the guest has arrived
+func (r Reservation) CheckedIn() bool {
+	return !r.CheckedInAt.IsZero()
+}
+
+// CheckedOut reports whether the guest has left
+func (r Reservation) CheckedOut() bool {
+	return !r.CheckedOutAt.IsZero()
+}
+
+// Deleted reports whether the reservation is in the trash
+func (r Reservation) Deleted() bool {
+	return !r.DeletedAt.IsZero()
 }
 
 // RoomRestriction is restriction of room model
@@ -63,6 +82,8 @@ type RoomRestriction struct {
 	RoomID        int
 	ReservationID int
 	RestrictionID int
+	ICalFeedID    int
+	ExternalUID   string
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Room          Room
@@ -78,3 +99,258 @@ type MailData struct {
 	Content  string
 	Template string
 }
+
+// APIKey is an admin-issued key used to call the JSON API
+type APIKey struct {
+	ID         int
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scope      string
+	UserID     int
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Revoked reports whether the key has been revoked
+func (k APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// Allows reports whether the key's scope covers the given scope, write keys may also read
+func (k APIKey) Allows(scope string) bool {
+	return k.Scope == scope || (k.Scope == "write" && scope == "read")
+}
+
+// APIKeyCall is one audited API request made with a key
+type APIKeyCall struct {
+	ID        int
+	APIKeyID  int
+	Method    string
+	Path      string
+	Status    int
+	RemoteIP  string
+	CreatedAt time.Time
+}
+
+// Webhook is an admin-configured endpoint that is sent reservation events
+type Webhook struct {
+	ID        int
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Subscribes reports whether the webhook wants the given event
+func (h Webhook) Subscribes(event string) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID         int
+	WebhookID  int
+	Event      string
+	Payload    string
+	Attempt    int
+	StatusCode int
+	Error      string
+	Success    bool
+	CreatedAt  time.Time
+}
+
+// WebhookEvent is an event waiting to be sent to webhooks,
+// when WebhookID is set it is only sent to that webhook
+type WebhookEvent struct {
+	Event     string
+	Payload   []byte
+	WebhookID int
+}
+
+// ICalFeed is an external calendar whose events block a room
+type ICalFeed struct {
+	ID        int
+	RoomID    int
+	Name      string
+	URL       string
+	LastRun   ICalImportRun
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Room      Room
+}
+
+// ICalImportRun is the log of one import of an iCal feed
+type ICalImportRun struct {
+	ID         int
+	ICalFeedID int
+	Added      int
+	Updated    int
+	Removed    int
+	Conflicts  int
+	Error      string
+	CreatedAt  time.Time
+}
+
+// ICalConflict is an external booking that overlaps a local reservation
+type ICalConflict struct {
+	Feed        ICalFeed
+	Restriction RoomRestriction
+	Reservation Reservation
+}
+
+// Reservation statuses used to filter lists
+const (
+	StatusNew       = "new"
+	StatusProcessed = "processed"
+)
+
+// ReservationFilter narrows a list of reservations, zero values match everything.
+// From and To are inclusive bounds on the arrival date. Every word of Search must be
+// found in the guest's name, email or phone.
+type ReservationFilter struct {
+	Search string
+	From   time.Time
+	To     time.Time
+	RoomID int
+	Status string
+}
+
+// ReservationSorts are the keys reservations can be sorted by
+var ReservationSorts = []string{"id", "last_name", "room", "arrival", "departure", "booked"}
+
+// ReservationQuery is one page of filtered reservations in a given order
+type ReservationQuery struct {
+	ReservationFilter
+
+	// Sort is one of ReservationSorts, prefixed with "-" for descending order
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+// Offset is the number of rows before the page
+func (q ReservationQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.PerPage
+}
+
+// Pages is the number of pages needed for total rows, at least one
+func (q ReservationQuery) Pages(total int) int {
+	if q.PerPage < 1 || total <= q.PerPage {
+		return 1
+	}
+	return (total + q.PerPage - 1) / q.PerPage
+}
+
+// DashboardSummary holds the headline figures for an inclusive range of dates.
+// Reservations, AverageStay and AverageLeadTime cover reservations arriving in the range,
+// NightsSold counts every reserved night falling inside it.
+type DashboardSummary struct {
+	From            time.Time
+	To              time.Time
+	Reservations    int
+	NightsSold      int
+	RoomNights      int
+	AverageStay     float64
+	AverageLeadTime float64
+	ArrivalsToday   int
+	DeparturesToday int
+	NewReservations int
+}
+
+// Occupancy is the share of room nights sold, from 0 to 1
+func (s DashboardSummary) Occupancy() float64 {
+	if s.RoomNights == 0 {
+		return 0
+	}
+	return float64(s.NightsSold) / float64(s.RoomNights)
+}
+
+// RoomOccupancy is how many nights of a room were sold in one month of a range.
+// Nights is the number of days of the month inside the range.
+type RoomOccupancy struct {
+	RoomID     int
+	RoomName   string
+	Month      time.Time
+	NightsSold int
+	Nights     int
+}
+
+// Occupancy is the share of the month's nights sold, from 0 to 1
+func (o RoomOccupancy) Occupancy() float64 {
+	if o.Nights == 0 {
+		return 0
+	}
+	return float64(o.NightsSold) / float64(o.Nights)
+}
+
+// FrontDesk is what happens on one day: who arrives, who leaves, who stays on and which rooms are blocked
+type FrontDesk struct {
+	Date       time.Time
+	Arrivals   []Reservation
+	Departures []Reservation
+	InHouse    []Reservation
+	Blocks     []RoomRestriction
+}
+
+// AuditEntry records one administrative change: who made it, from where, and what changed. Changes made
+// through the API name the key that authenticated them.
+type AuditEntry struct {
+	ID        int
+	UserID    int
+	APIKeyID  int
+	RemoteIP  string
+	Action    string
+	Entity    string
+	EntityID  int
+	Changes   []AuditChange
+	CreatedAt time.Time
+	User      User
+	APIKey    APIKey
+}
+
+// AuditChange is the value of one field before and after a change. Empty values stand for fields
+// of an entity that was created or deleted.
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditQuery is one page of audit entries matching a filter, newest first. Zero values match everything.
+type AuditQuery struct {
+	Entity   string
+	EntityID int
+	UserID   int
+	Action   string
+	Page     int
+	PerPage  int
+}
+
+// Offset is the number of rows before the page
+func (q AuditQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.PerPage
+}
+
+// Pages is the number of pages needed for total rows, at least one
+func (q AuditQuery) Pages(total int) int {
+	if q.PerPage < 1 || total <= q.PerPage {
+		return 1
+	}
+	return (total + q.PerPage - 1) / q.PerPage
+}
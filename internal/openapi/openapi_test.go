@@ -0,0 +1,88 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+)
+
+type testNested struct {
+	Name string `json:"name"`
+}
+
+type testModel struct {
+	ID        int               `json:"id"`
+	Note      string            `json:"note,omitempty"`
+	Available *bool             `json:"available,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Tags      []string          `json:"tags"`
+	Fields    map[string]string `json:"fields"`
+	Nested    testNested        `json:"nested"`
+	Ignored   string            `json:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf(testModel{})
+
+	if s.Type != "object" {
+		t.Fatalf("expected object schema, got %q", s.Type)
+	}
+
+	var tests = []struct {
+		property string
+		typ      string
+	}{
+		{"id", "integer"},
+		{"note", "string"},
+		{"available", "boolean"},
+		{"created_at", "string"},
+		{"tags", "array"},
+		{"fields", "object"},
+		{"nested", "object"},
+	}
+
+	for _, e := range tests {
+		p, ok := s.Properties[e.property]
+		if !ok {
+			t.Errorf("missing property %s", e.property)
+			continue
+		}
+		if p.Type != e.typ {
+			t.Errorf("for %s, expected type %s but got %s", e.property, e.typ, p.Type)
+		}
+	}
+
+	if _, ok := s.Properties["Ignored"]; ok {
+		t.Error("field tagged json:\"-\" should not be in the schema")
+	}
+
+	if s.Properties["nested"].Properties["name"] == nil {
+		t.Error("nested struct properties not generated")
+	}
+
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	if !required["id"] || required["note"] || required["available"] {
+		t.Errorf("wrong required fields: %v", s.Required)
+	}
+}
+
+func TestDocument_AddHas(t *testing.T) {
+	var d Document
+
+	d.Add("get", "/api/v1/rooms", &Operation{OperationID: "listRooms"})
+
+	if !d.Has("GET", "/api/v1/rooms") {
+		t.Error("expected GET /api/v1/rooms to be described")
+	}
+
+	if d.Has("POST", "/api/v1/rooms") {
+		t.Error("did not expect POST /api/v1/rooms to be described")
+	}
+
+	if d.Has("GET", "/api/v1/nothing") {
+		t.Error("did not expect GET /api/v1/nothing to be described")
+	}
+}
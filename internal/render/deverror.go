@@ -0,0 +1,100 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// templateErrorPos finds the file and line in errors such as
+// template: home.page.tmpl:12: unexpected "}" in operand
+var templateErrorPos = regexp.MustCompile(`template: ([^:\s]+\.tmpl):(\d+):`)
+
+// TemplateError is where a template failed, with the lines around it
+type TemplateError struct {
+	Err     string
+	File    string
+	Line    int
+	Context []SourceLine
+}
+
+// SourceLine is a numbered line of a template file
+type SourceLine struct {
+	Number  int
+	Text    string
+	Failing bool
+}
+
+// sourceContext is how many lines are shown either side of the failing one
+const sourceContext = 5
+
+// NewTemplateError locates err in the template files of fsys. File and Line are left empty when the error
+// doesn't say where it happened.
+func NewTemplateError(fsys fs.FS, err error) TemplateError {
+	te := TemplateError{Err: err.Error()}
+
+	match := templateErrorPos.FindStringSubmatch(te.Err)
+	if match == nil {
+		return te
+	}
+
+	te.File = match[1]
+	te.Line, _ = strconv.Atoi(match[2])
+
+	data, readErr := fs.ReadFile(fsys, te.File)
+	if readErr != nil {
+		return te
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		if n < te.Line-sourceContext {
+			continue
+		}
+		if n > te.Line+sourceContext {
+			break
+		}
+		te.Context = append(te.Context, SourceLine{Number: n, Text: scanner.Text(), Failing: n == te.Line})
+	}
+
+	return te
+}
+
+// devErrorPage stands on its own, since the layouts may be what is broken
+var devErrorPage = template.Must(template.New("dev-error").Parse(`<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Template error</title>
+    <style>
+        body { font-family: sans-serif; margin: 2em; color: #212529; }
+        h1 { color: #dc3545; }
+        pre { background: #f8f9fa; padding: 1em; overflow-x: auto; }
+        .failing { background: #f8d7da; display: block; }
+        .number { color: #6c757d; user-select: none; }
+    </style>
+</head>
+<body>
+    <h1>Template error</h1>
+    {{if .File}}<p><strong>{{.File}}</strong>, line {{.Line}}</p>{{end}}
+    <pre>{{.Err}}</pre>
+    {{if .Context}}
+    <pre>{{range .Context}}<span{{if .Failing}} class="failing"{{end}}><span class="number">{{printf "%4d" .Number}}</span>  {{.Text}}</span>
+{{end}}</pre>
+    {{end}}
+    <p>The page reloads the templates as soon as the file is saved again.</p>
+</body>
+</html>
+`))
+
+// DevError shows a template error with its file and line, for development only since it exposes the source
+func DevError(w http.ResponseWriter, fsys fs.FS, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	_ = devErrorPage.Execute(w, NewTemplateError(fsys, err))
+}
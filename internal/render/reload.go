@@ -0,0 +1,153 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/logging"
+)
+
+// Reloader keeps a template cache in step with the template files while developing. It checks the *.tmpl
+// files for changes and only rebuilds the cache when one is added, removed or modified, so requests in between
+// are served from the cache. A failed rebuild is kept as the error until the files are fixed.
+type Reloader struct {
+	fsys     fs.FS
+	interval time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]*template.Template
+	err   error
+	stamp string
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// reloader is watching the templates when set, instead of the app's template cache being used
+var reloader *Reloader
+
+// NewReloader builds the template cache from fsys and returns a reloader that checks it every interval once
+// watching
+func NewReloader(fsys fs.FS, interval time.Duration) *Reloader {
+	r := &Reloader{
+		fsys:     fsys,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+
+	r.reload()
+
+	return r
+}
+
+// WatchTemplates starts reloading the templates in fsys as they change, and renders from the reloaded cache
+func WatchTemplates(fsys fs.FS, interval time.Duration) *Reloader {
+	r := NewReloader(fsys, interval)
+	reloader = r
+
+	go r.watch()
+
+	return r
+}
+
+// Templates returns the current cache, or the error the templates fail to parse with
+func (r *Reloader) Templates() (map[string]*template.Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cache, r.err
+}
+
+// Check rebuilds the cache if the templates changed since it was last built, and reports whether they did
+func (r *Reloader) Check() bool {
+	stamp, err := r.fingerprint()
+	if err != nil {
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+		return true
+	}
+
+	r.mu.RLock()
+	same := stamp == r.stamp
+	r.mu.RUnlock()
+
+	if same {
+		return false
+	}
+
+	r.reload()
+
+	return true
+}
+
+// Stop ends the watching
+func (r *Reloader) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+}
+
+func (r *Reloader) watch() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if r.Check() && app != nil {
+				if _, err := r.Templates(); err != nil {
+					app.Logger.Error("templates not reloaded", logging.Err(err))
+				} else {
+					app.Logger.Info("templates reloaded")
+				}
+			}
+		}
+	}
+}
+
+// reload builds the cache from the files as they are now
+func (r *Reloader) reload() {
+	stamp, err := r.fingerprint()
+	if err != nil {
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+		return
+	}
+
+	tc, err := createTemplateCache(r.fsys)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stamp = stamp
+	r.err = err
+	if err == nil {
+		r.cache = tc
+	}
+}
+
+// fingerprint lists the template files with their sizes and modification times, which changes whenever one does
+func (r *Reloader) fingerprint() (string, error) {
+	names, err := fs.Glob(r.fsys, "*.tmpl")
+	if err != nil {
+		return "", err
+	}
+
+	stamp := ""
+	for _, name := range names {
+		info, err := fs.Stat(r.fsys, name)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return stamp, nil
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/models"
+)
+
+const (
+	testLayout = `{{define "base"}}<p>{{block "content" .}}{{end}}</p>{{end}}`
+	testPage   = `{{template "base" .}}
+{{define "content"}}hello{{end}}`
+	brokenPage = `{{template "base" .}}
+{{define "content"}}
+hello {{.Flash}
+{{end}}`
+)
+
+func templateDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bookings-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	writeTemplate(t, dir, "base.layout.tmpl", testLayout)
+	writeTemplate(t, dir, "home.page.tmpl", testPage)
+
+	return dir
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := templateDir(t)
+	r := NewReloader(os.DirFS(dir), time.Hour)
+
+	if tc, err := r.Templates(); err != nil || tc["home.page.tmpl"] == nil {
+		t.Fatalf("expected the initial cache to be built, got %v", err)
+	}
+
+	if r.Check() {
+		t.Error("expected no rebuild when nothing changed")
+	}
+
+	writeTemplate(t, dir, "home.page.tmpl", brokenPage)
+
+	if !r.Check() {
+		t.Fatal("expected a rebuild after a template changed")
+	}
+
+	_, err := r.Templates()
+	if err == nil {
+		t.Fatal("expected the broken template to fail")
+	}
+
+	te := NewTemplateError(os.DirFS(dir), err)
+	if te.File != "home.page.tmpl" || te.Line != 3 {
+		t.Errorf("got the error at %s:%d, wanted home.page.tmpl:3", te.File, te.Line)
+	}
+
+	writeTemplate(t, dir, "home.page.tmpl", testPage)
+	r.Check()
+
+	if _, err := r.Templates(); err != nil {
+		t.Errorf("expected the fixed template to parse, got %v", err)
+	}
+}
+
+func TestTemplateShowsDevError(t *testing.T) {
+	dir := templateDir(t)
+	writeTemplate(t, dir, "home.page.tmpl", brokenPage)
+
+	reloader = NewReloader(os.DirFS(dir), time.Hour)
+	useCache := app.UseCache
+	app.UseCache = false
+	defer func() {
+		reloader = nil
+		app.UseCache = useCache
+	}()
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	err = Template(rr, r, "home.page.tmpl", &models.TemplateData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != 500 {
+		t.Errorf("got status %d, wanted 500", rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{"home.page.tmpl</strong>, line 3", "hello {{.Flash}"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the error page to contain %q, got %s", want, body)
+		}
+	}
+}
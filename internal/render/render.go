@@ -5,13 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"io/fs"
 	"net/http"
-	"path/filepath"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/logging"
 	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/templates"
 	"github.com/justinas/nosurf"
 )
 
@@ -21,13 +24,19 @@ var functions = template.FuncMap{
 	"formatDate": FormatDate,
 	"iterate":    Iterate,
 	"add":        Add,
+	"static":     StaticPath,
 }
 
 var app *config.AppConfig
-var pathToTemplates = "./templates"
+
+// templateFS is where the templates are parsed from, the ones built into the binary unless the app says otherwise
+var templateFS fs.FS = templates.FS
 
 func NewRenderer(a *config.AppConfig) {
 	app = a
+	if a.Assets.Templates != nil {
+		templateFS = a.Assets.Templates
+	}
 }
 
 // HumanDate returns time in YYYY-MM-DD format
@@ -52,6 +61,15 @@ func Add(a, b int) int {
 	return a + b
 }
 
+// StaticPath returns the URL of a static file such as css/styles.css, versioned by its content so browsers can
+// cache it
+func StaticPath(name string) string {
+	if app == nil || app.Static == nil {
+		return "/static/" + strings.TrimPrefix(name, "/")
+	}
+	return app.Static.Path(name)
+}
+
 func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateData {
 	td.CSRFToken = nosurf.Token(r)
 	td.Warning = app.Session.PopString(r.Context(), "warning")
@@ -63,21 +81,25 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	return td
 }
 
+// Template renders a page into w. Nothing is written when it fails, so the caller can still answer with an
+// error page.
 func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) error {
 
-	tc := make(map[string]*template.Template)
-
-	if app.UseCache {
-		tc = app.TemplateCache
-	} else {
-		tc, _ = CreateTemplateCache()
+	tc, err := templateCache()
+	if err != nil {
+		if reloader != nil {
+			// the page has been answered, so there is nothing left for the caller to handle
+			DevError(w, reloader.fsys, err)
+			return nil
+		}
+		return err
 	}
 
 	t, ok := tc[tmpl]
 	//fmt.Println(tmpl)
 	if !ok {
 		//log.Fatal("Could not get template from template cache")
-		return errors.New("can't get template from cache")
+		return fmt.Errorf("can't get template %s from cache", tmpl)
 	}
 
 	//parsedTemplate, _ := template.ParseFiles("./templates/" + tmpl)
@@ -88,33 +110,112 @@ func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.Te
 
 	td = AddDefaultData(td, r)
 
-	err := t.Execute(buf, td)
+	err = t.Execute(buf, td)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("cannot render %s: %v", tmpl, err)
 	}
 
 	_, err = buf.WriteTo(w)
 	if err != nil {
-		log.Println("error writing template to browser", err)
+		app.Logger.ErrorContext(r.Context(), "error writing template to browser", logging.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// templateCache returns the templates to render from: the app's cache, the one kept up to date while
+// developing, or else one parsed just now
+func templateCache() (map[string]*template.Template, error) {
+	switch {
+	case app.UseCache:
+		return app.TemplateCache, nil
+	case reloader != nil:
+		return reloader.Templates()
+	default:
+		return CreateTemplateCache()
+	}
+}
+
+// TemplatesReady reports why pages can't be rendered: a template cache that is missing or empty, or templates
+// that fail to parse while they are being reloaded
+func TemplatesReady() error {
+	if app == nil {
+		return errors.New("the renderer is not set up")
+	}
+
+	tc, err := templateCache()
+	if err != nil {
 		return err
 	}
 
+	if len(tc) == 0 {
+		return errors.New("there are no templates in the cache")
+	}
+
 	return nil
 }
 
+// errorPages are the pages shown for error statuses, a method that isn't allowed looks like a missing page
+var errorPages = map[int]string{
+	http.StatusForbidden:           "403.page.tmpl",
+	http.StatusNotFound:            "404.page.tmpl",
+	http.StatusMethodNotAllowed:    "404.page.tmpl",
+	http.StatusInternalServerError: "500.page.tmpl",
+}
+
+// ErrorPage answers with an error status and its page in the site layout. Statuses without a page, or a page
+// that fails to render, get a plain text message instead.
+func ErrorPage(w http.ResponseWriter, status int) {
+	buf := new(bytes.Buffer)
+
+	if err := executeErrorPage(buf, status); err != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+func executeErrorPage(buf *bytes.Buffer, status int) error {
+	page, ok := errorPages[status]
+	if !ok || app == nil {
+		return fmt.Errorf("no page for status %d", status)
+	}
+
+	tc, err := templateCache()
+	if err != nil {
+		return err
+	}
+
+	t, ok := tc[page]
+	if !ok {
+		return fmt.Errorf("can't get template %s from cache", page)
+	}
+
+	return t.Execute(buf, &models.TemplateData{})
+}
+
 // *template.Template是一個解析過後的html(...等)的file，也就是一些儲存text的fragment的在的記憶體位置
 func CreateTemplateCache() (map[string]*template.Template, error) {
+	return createTemplateCache(templateFS)
+}
+
+// createTemplateCache parses every page in fsys together with the layouts
+func createTemplateCache(fsys fs.FS) (map[string]*template.Template, error) {
 
 	myCache := make(map[string]*template.Template)
 
-	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.tmpl", pathToTemplates))
+	pages, err := fs.Glob(fsys, "*.page.tmpl")
 	if err != nil {
 		return myCache, err
 	}
 
 	for _, page := range pages {
 
-		name := filepath.Base(page)
+		name := path.Base(page)
 
 		// 一個Tempalte重要的包含物 Name & content
 		// Template是一個定義好的struct 裡面包含 Tree struct 跟 nameSpace
@@ -127,9 +228,8 @@ func CreateTemplateCache() (map[string]*template.Template, error) {
 		// 2.也包含將layout的定義和內容加入page中
 		// 3.可以自訂義新的tempalte的function(增加靈活性)
 
-		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
+		ts, err := template.New(name).Funcs(functions).ParseFS(fsys, page)
 		if err != nil {
-			log.Print(err)
 			return myCache, err
 		}
 		//fmt.Println(ts)
@@ -151,7 +251,7 @@ func CreateTemplateCache() (map[string]*template.Template, error) {
 		// 	}
 		// }
 
-		ts, err = ts.ParseGlob(fmt.Sprintf("%s/*.layout.tmpl", pathToTemplates))
+		ts, err = ts.ParseFS(fsys, "*.layout.tmpl")
 		if err != nil {
 			return myCache, err
 		}
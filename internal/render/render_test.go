@@ -1,7 +1,10 @@
 package render
 
 import (
+	"html/template"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gummy789j/bookings/internal/models"
@@ -28,8 +31,6 @@ func TestAddDefaultData(t *testing.T) {
 
 func TestRenderTemplate(t *testing.T) {
 
-	pathToTemplates = "../../templates"
-
 	tc, err := CreateTemplateCache()
 	if err != nil {
 		t.Error(err)
@@ -78,9 +79,101 @@ func TestNewTemplates(t *testing.T) {
 
 func TestCreateTemplateCache(t *testing.T) {
 
-	pathToTemplates = "../../templates"
 	_, err := CreateTemplateCache()
 	if err != nil {
 		t.Error(err)
 	}
 }
+
+func TestTemplateReturnsExecutionErrors(t *testing.T) {
+	useCache, cache := app.UseCache, app.TemplateCache
+	defer func() { app.UseCache, app.TemplateCache = useCache, cache }()
+
+	app.UseCache = true
+	app.TemplateCache = map[string]*template.Template{
+		"broken.page.tmpl": template.Must(template.New("broken.page.tmpl").Parse(`before {{.NoSuchField}} after`)),
+	}
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	err = Template(rr, r, "broken.page.tmpl", &models.TemplateData{})
+	if err == nil || !strings.Contains(err.Error(), "broken.page.tmpl") {
+		t.Errorf("expected the execution error to be returned, got %v", err)
+	}
+
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected nothing written for a failed page, got %q", rr.Body.String())
+	}
+}
+
+func TestErrorPage(t *testing.T) {
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useCache, cache := app.UseCache, app.TemplateCache
+	defer func() { app.UseCache, app.TemplateCache = useCache, cache }()
+
+	app.UseCache = true
+	app.TemplateCache = tc
+
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusNotFound, "Page not found"},
+		{http.StatusForbidden, "Access denied"},
+		{http.StatusInternalServerError, "Something went wrong"},
+		{http.StatusTeapot, "I'm a teapot"},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		ErrorPage(rr, e.status)
+
+		if rr.Code != e.status {
+			t.Errorf("got status %d, wanted %d", rr.Code, e.status)
+		}
+
+		if !strings.Contains(rr.Body.String(), e.want) {
+			t.Errorf("%d: expected %q, got %s", e.status, e.want, rr.Body.String())
+		}
+	}
+
+	// a broken error page still answers with the status
+	app.TemplateCache = map[string]*template.Template{}
+	rr := httptest.NewRecorder()
+	ErrorPage(rr, http.StatusInternalServerError)
+
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "Internal Server Error") {
+		t.Errorf("expected a plain 500, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTemplatesReady(t *testing.T) {
+	useCache, cache := app.UseCache, app.TemplateCache
+	defer func() { app.UseCache, app.TemplateCache = useCache, cache }()
+
+	app.UseCache = true
+
+	app.TemplateCache = nil
+	if err := TemplatesReady(); err == nil {
+		t.Error("expected a missing template cache to be reported")
+	}
+
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.TemplateCache = tc
+	if err := TemplatesReady(); err != nil {
+		t.Errorf("expected the templates to be ready, got %v", err)
+	}
+}
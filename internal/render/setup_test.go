@@ -2,7 +2,7 @@ package render
 
 import (
 	"encoding/gob"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
@@ -10,6 +10,7 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/logging"
 	"github.com/gummy789j/bookings/internal/models"
 )
 
@@ -25,11 +26,7 @@ func TestMain(m *testing.M) {
 	//  change this when in production
 	testApp.InProduction = false
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	testApp.InfoLog = infoLog
-
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	testApp.ErrorLog = errorLog
+	testApp.Logger = logging.New(os.Stdout, testApp.InProduction, slog.LevelInfo)
 
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
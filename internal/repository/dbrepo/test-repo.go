@@ -1,10 +1,14 @@
 package dbrepo
 
 import (
+	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
+	"github.com/gummy789j/bookings/internal/helpers"
 	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository"
 )
 
 func (this *testDBRepo) AllUsers() bool {
@@ -36,7 +40,12 @@ func (this *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time,
 	if roomID == 1000 {
 		return false, errors.New("Some error!")
 	}
-	return false, nil
+
+	if roomID == 1002 {
+		return false, nil
+	}
+
+	return true, nil
 }
 
 // SearchAvailabilityForAllRooms return a slice of available rooms, if any; for given date range
@@ -56,6 +65,14 @@ func (this *testDBRepo) GetRoomByID(id int) (models.Room, error) {
 		return room, errors.New("Some error!")
 	}
 
+	if id == 404 {
+		return room, sql.ErrNoRows
+	}
+
+	room.ID = id
+	room.RoomName = "Test Room"
+	room.ICalSecret = "secret"
+
 	return room, nil
 
 }
@@ -68,6 +85,32 @@ func (this *testDBRepo) GetUserByID(id int) (models.User, error) {
 	return user, nil
 }
 
+// GetUserByEmail returns a user by email address
+func (this *testDBRepo) GetUserByEmail(email string) (models.User, error) {
+
+	if email == "missing@here.com" {
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return models.User{ID: 1, FirstName: "Admin", LastName: "User", Email: email, AccessLevel: 3}, nil
+}
+
+// InsertUser inserts a user, whose password must already be hashed, and returns its id
+func (this *testDBRepo) InsertUser(user models.User) (int, error) {
+
+	if user.Email == "taken@here.com" {
+		return 0, errors.New("duplicate key value violates unique constraint \"users_email_idx\"")
+	}
+
+	return 2, nil
+}
+
+// UpdateUserPassword replaces the password hash of a user
+func (this *testDBRepo) UpdateUserPassword(id int, hash string) error {
+
+	return nil
+}
+
 // UpdateUser updates a user in the database
 func (this *testDBRepo) UpdateUser(user models.User) error {
 
@@ -101,6 +144,25 @@ func (this *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 
 	var res models.Reservation
 
+	if id == 1000 {
+		return res, sql.ErrNoRows
+	}
+
+	if id == 1001 {
+		return res, errors.New("Some error!")
+	}
+
+	res.ID = id
+
+	// 3 is in house and 4 has left
+	if id == 3 || id == 4 {
+		res.CheckedInAt = time.Now()
+	}
+
+	if id == 4 {
+		res.CheckedOutAt = time.Now()
+	}
+
 	return res, nil
 }
 
@@ -110,15 +172,23 @@ func (this *testDBRepo) UpdateReservation(res models.Reservation) error {
 	return nil
 }
 
-// DeleteReservarion deletes reservation by id
+// DeleteReservation moves a reservation to the trash
 func (this *testDBRepo) DeleteReservation(id int) error {
 
+	if id == 1003 {
+		return errors.New("Some error!")
+	}
+
 	return nil
 }
 
 // UpdateProcessedForReservation updates processed for a reservation by id
 func (this *testDBRepo) UpdateProcessedForReservation(id, processed int) error {
 
+	if id == 1003 {
+		return errors.New("Some error!")
+	}
+
 	return nil
 }
 
@@ -127,6 +197,12 @@ func (this *testDBRepo) AllRooms() ([]models.Room, error) {
 
 	var rooms []models.Room
 
+	rooms = append(rooms,
+		models.Room{ID: 2, RoomName: "General's Quarters", ICalSecret: "secret"},
+		models.Room{ID: 3, RoomName: "Major's Suite", ICalSecret: "secret"},
+		models.Room{ID: 1002, RoomName: "Fully Booked Room"},
+	)
+
 	return rooms, nil
 }
 
@@ -144,8 +220,591 @@ func (this *testDBRepo) InsertBlockForRoom(roomID int, startDate time.Time) erro
 	return nil
 }
 
+// GetBlockByID returns an owner block
+func (this *testDBRepo) GetBlockByID(id int) (models.RoomRestriction, error) {
+
+	if id == 404 {
+		return models.RoomRestriction{}, sql.ErrNoRows
+	}
+
+	if id == 1000 {
+		return models.RoomRestriction{}, errors.New("Some error!")
+	}
+
+	start := time.Date(2026, 11, 4, 0, 0, 0, 0, time.UTC)
+
+	return models.RoomRestriction{ID: id, RoomID: 2, RestrictionID: 2, StartDate: start, EndDate: start.AddDate(0, 0, 1)}, nil
+}
+
 // DeleteBlockByID deletes a room restriction for block
 func (this *testDBRepo) DeleteBlockByID(id int) error {
 
+	if id == 1001 {
+		return errors.New("Some error!")
+	}
+
+	return nil
+}
+
+// GetRestrictionsForRoomSince returns the restrictions of a room that end after since
+func (this *testDBRepo) GetRestrictionsForRoomSince(roomID int, since time.Time) ([]models.RoomRestriction, error) {
+
+	var restrictions []models.RoomRestriction
+
+	if roomID == 1000 {
+		return restrictions, errors.New("Some error!")
+	}
+
+	updated := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	restrictions = append(restrictions,
+		models.RoomRestriction{
+			ID:            1,
+			StartDate:     since.AddDate(0, 0, 2),
+			EndDate:       since.AddDate(0, 0, 5),
+			RoomID:        roomID,
+			ReservationID: 1,
+			RestrictionID: 1,
+			UpdatedAt:     updated,
+		},
+		models.RoomRestriction{
+			ID:            2,
+			StartDate:     since.AddDate(0, 0, 7),
+			EndDate:       since.AddDate(0, 0, 8),
+			RoomID:        roomID,
+			RestrictionID: 2,
+			UpdatedAt:     updated,
+		},
+	)
+
+	// room 3 is room 2 after its block was removed, which leaves the latest UpdatedAt as it was
+	if roomID == 3 {
+		restrictions = restrictions[:1]
+	}
+
+	return restrictions, nil
+}
+
+// UpdateRoomICalSecret replaces the secret in a room's iCal feed URL
+func (this *testDBRepo) UpdateRoomICalSecret(roomID int, secret string) error {
+
+	if roomID == 1000 {
+		return errors.New("Some error!")
+	}
+
+	if roomID == 404 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// InsertAPIKey stores a new API key and returns its id
+func (this *testDBRepo) InsertAPIKey(key models.APIKey) (int, error) {
+
+	if key.Name == "fail" {
+		return 0, errors.New("Some error!")
+	}
+
+	return 1, nil
+}
+
+// AllAPIKeys returns every API key, newest first
+func (this *testDBRepo) AllAPIKeys() ([]models.APIKey, error) {
+
+	var keys []models.APIKey
+
+	return keys, nil
+}
+
+// GetAPIKeyByID returns one API key by id
+func (this *testDBRepo) GetAPIKeyByID(id int) (models.APIKey, error) {
+
+	if id == 1000 {
+		return models.APIKey{}, sql.ErrNoRows
+	}
+
+	return models.APIKey{ID: id, Scope: "read"}, nil
+}
+
+// GetAPIKeyByHash returns the API key with the given sha256 hash,
+// the tokens "read-key", "write-key" and "revoked-key" are known
+func (this *testDBRepo) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+
+	switch hash {
+	case helpers.HashAPIKey("read-key"):
+		return models.APIKey{ID: 1, Scope: "read", KeyHash: hash}, nil
+	case helpers.HashAPIKey("write-key"):
+		return models.APIKey{ID: 2, Scope: "write", KeyHash: hash}, nil
+	case helpers.HashAPIKey("revoked-key"):
+		return models.APIKey{ID: 3, Scope: "write", KeyHash: hash, RevokedAt: time.Now()}, nil
+	}
+
+	return models.APIKey{}, sql.ErrNoRows
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (this *testDBRepo) RevokeAPIKey(id int) error {
+
+	return nil
+}
+
+// TouchAPIKey records that an API key has just been used
+func (this *testDBRepo) TouchAPIKey(id int) error {
+
+	return nil
+}
+
+// InsertAPIKeyCall stores an audit record for one API call
+func (this *testDBRepo) InsertAPIKeyCall(call models.APIKeyCall) error {
+
+	return nil
+}
+
+// RecentAPIKeyCalls returns the latest audited calls for a key
+func (this *testDBRepo) RecentAPIKeyCalls(keyID, limit int) ([]models.APIKeyCall, error) {
+
+	var calls []models.APIKeyCall
+
+	return calls, nil
+}
+
+// InsertWebhook stores a new webhook endpoint and returns its id
+func (this *testDBRepo) InsertWebhook(hook models.Webhook) (int, error) {
+
+	return 1, nil
+}
+
+// AllWebhooks returns every webhook endpoint
+func (this *testDBRepo) AllWebhooks() ([]models.Webhook, error) {
+
+	var hooks []models.Webhook
+
+	return hooks, nil
+}
+
+// ActiveWebhooks returns the webhook endpoints that should receive events
+func (this *testDBRepo) ActiveWebhooks() ([]models.Webhook, error) {
+
+	var hooks []models.Webhook
+
+	return hooks, nil
+}
+
+// GetWebhookByID returns one webhook endpoint by id
+func (this *testDBRepo) GetWebhookByID(id int) (models.Webhook, error) {
+
+	if id == 1000 {
+		return models.Webhook{}, sql.ErrNoRows
+	}
+
+	return models.Webhook{ID: id, Active: true}, nil
+}
+
+// DeleteWebhook deletes a webhook endpoint and its delivery log
+func (this *testDBRepo) DeleteWebhook(id int) error {
+
+	return nil
+}
+
+// InsertWebhookDelivery logs one delivery attempt
+func (this *testDBRepo) InsertWebhookDelivery(d models.WebhookDelivery) error {
+
+	return nil
+}
+
+// WebhookDeliveries returns the latest delivery attempts for a webhook
+func (this *testDBRepo) WebhookDeliveries(webhookID, limit int) ([]models.WebhookDelivery, error) {
+
+	var deliveries []models.WebhookDelivery
+
+	return deliveries, nil
+}
+
+// GetWebhookDeliveryByID returns one delivery attempt by id
+func (this *testDBRepo) GetWebhookDeliveryByID(id int) (models.WebhookDelivery, error) {
+
+	if id == 1000 {
+		return models.WebhookDelivery{}, sql.ErrNoRows
+	}
+
+	return models.WebhookDelivery{ID: id, WebhookID: 1, Event: "reservation.created", Payload: "{}"}, nil
+}
+
+// InsertICalFeed registers an external calendar for a room and returns its id
+func (this *testDBRepo) InsertICalFeed(feed models.ICalFeed) (int, error) {
+
+	if feed.Name == "fail" {
+		return 0, errors.New("Some error!")
+	}
+
+	return 1, nil
+}
+
+// AllICalFeeds returns every external calendar with its room and latest import run
+func (this *testDBRepo) AllICalFeeds() ([]models.ICalFeed, error) {
+
+	var feeds []models.ICalFeed
+
+	feed, _ := this.GetICalFeedByID(1)
+	feeds = append(feeds, feed)
+
+	return feeds, nil
+}
+
+// GetICalFeedByID returns one external calendar by id
+func (this *testDBRepo) GetICalFeedByID(id int) (models.ICalFeed, error) {
+
+	var feed models.ICalFeed
+
+	if id == 1000 {
+		return feed, sql.ErrNoRows
+	}
+
+	feed.ID = id
+	feed.RoomID = 1
+	feed.Name = "Channel"
+	feed.URL = "https://example.com/room.ics"
+	feed.Room.ID = 1
+	feed.Room.RoomName = "Test Room"
+
+	return feed, nil
+}
+
+// DeleteICalFeed deletes an external calendar, its blocks and its run log
+func (this *testDBRepo) DeleteICalFeed(id int) error {
+
+	return nil
+}
+
+// ICalFeedBlocks returns the restrictions imported from an external calendar
+func (this *testDBRepo) ICalFeedBlocks(feedID int) ([]models.RoomRestriction, error) {
+
+	var restrictions []models.RoomRestriction
+
+	return restrictions, nil
+}
+
+// SaveICalFeedBlocks applies the changes found by one import of an external calendar
+func (this *testDBRepo) SaveICalFeedBlocks(feedID int, add, update []models.RoomRestriction, removeIDs []int) error {
+
+	return nil
+}
+
+// InsertICalImportRun logs one import of an external calendar
+func (this *testDBRepo) InsertICalImportRun(run models.ICalImportRun) error {
+
+	return nil
+}
+
+// ICalImportRuns returns the latest import runs of an external calendar
+func (this *testDBRepo) ICalImportRuns(feedID, limit int) ([]models.ICalImportRun, error) {
+
+	var runs []models.ICalImportRun
+
+	runs = append(runs, models.ICalImportRun{ID: 1, ICalFeedID: feedID, Added: 2, CreatedAt: time.Now()})
+
+	return runs, nil
+}
+
+// ICalConflicts returns the external bookings that overlap a local reservation
+func (this *testDBRepo) ICalConflicts(feedID int) ([]models.ICalConflict, error) {
+
+	var conflicts []models.ICalConflict
+
+	return conflicts, nil
+}
+
+// SearchReservations returns a page of the reservations matching a query and how many match in all
+func (this *testDBRepo) SearchReservations(q models.ReservationQuery) ([]models.Reservation, int, error) {
+
+	var matching []models.Reservation
+
+	err := this.EachReservation(q.ReservationFilter, func(res models.Reservation) error {
+		matching = append(matching, res)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if q.Sort == "-arrival" {
+		for i, j := 0, len(matching)-1; i < j; i, j = i+1, j-1 {
+			matching[i], matching[j] = matching[j], matching[i]
+		}
+	}
+
+	start, end := q.Offset(), q.Offset()+q.PerPage
+	if start > len(matching) {
+		start = len(matching)
+	}
+	if end > len(matching) || q.PerPage < 1 {
+		end = len(matching)
+	}
+
+	return matching[start:end], len(matching), nil
+}
+
+// EachReservation calls fn for every reservation matching a filter
+func (this *testDBRepo) EachReservation(f models.ReservationFilter, fn func(models.Reservation) error) error {
+
+	if f.RoomID == 1000 {
+		return errors.New("Some error!")
+	}
+
+	arrival := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+
+	all := []models.Reservation{
+		{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@here.com", StartDate: arrival, EndDate: arrival.AddDate(0, 0, 3), RoomID: 1, Room: models.Room{ID: 1, RoomName: "General's Quarters"}},
+		{ID: 2, FirstName: "Jane", LastName: "Doe", Email: "jane@here.com", StartDate: arrival.AddDate(0, 0, 7), EndDate: arrival.AddDate(0, 0, 9), RoomID: 2, Processed: 1, Room: models.Room{ID: 2, RoomName: "Major's Suite"}},
+	}
+
+	for _, res := range all {
+		if f.RoomID > 0 && res.RoomID != f.RoomID {
+			continue
+		}
+		if (f.Status == models.StatusNew && res.Processed != 0) || (f.Status == models.StatusProcessed && res.Processed != 1) {
+			continue
+		}
+		if f.Search != "" && !strings.Contains(strings.ToLower(res.FirstName+" "+res.LastName+" "+res.Email), strings.ToLower(f.Search)) {
+			continue
+		}
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportReservations stores reservations and owner blocks in a single transaction, setting their IDs
+func (this *testDBRepo) ImportReservations(reservations []models.Reservation, blocks []models.RoomRestriction) error {
+
+	for _, res := range reservations {
+		if res.FirstName == "Failing" {
+			return errors.New("Some error!")
+		}
+	}
+
+	for i := range reservations {
+		reservations[i].ID = 100 + i
+	}
+
+	for i := range blocks {
+		blocks[i].ID = 200 + i
+	}
+
+	return nil
+}
+
+// DashboardSummary computes the headline figures for the dashboard over an inclusive range of dates
+func (this *testDBRepo) DashboardSummary(from, to time.Time) (models.DashboardSummary, error) {
+
+	if from.Year() == 1000 {
+		return models.DashboardSummary{}, errors.New("Some error!")
+	}
+
+	return models.DashboardSummary{
+		From:            from,
+		To:              to,
+		Reservations:    2,
+		NightsSold:      5,
+		RoomNights:      20,
+		AverageStay:     2.5,
+		AverageLeadTime: 12,
+		ArrivalsToday:   1,
+		DeparturesToday: 0,
+		NewReservations: 1,
+	}, nil
+}
+
+// RoomOccupancy returns the nights sold for every room in every month of an inclusive range of dates
+func (this *testDBRepo) RoomOccupancy(from, to time.Time) ([]models.RoomOccupancy, error) {
+
+	if from.Year() == 1000 {
+		return nil, errors.New("Some error!")
+	}
+
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return []models.RoomOccupancy{
+		{RoomID: 1, RoomName: "General's Quarters", Month: month, NightsSold: 3, Nights: 30},
+		{RoomID: 2, RoomName: "Major's Suite", Month: month, NightsSold: 15, Nights: 30},
+	}, nil
+}
+
+// ReservationsArriving returns the reservations starting on a date
+func (this *testDBRepo) ReservationsArriving(date time.Time) ([]models.Reservation, error) {
+
+	if date.Year() == 1000 {
+		return nil, errors.New("Some error!")
+	}
+
+	return []models.Reservation{
+		{ID: 1, FirstName: "John", LastName: "Smith", StartDate: date, EndDate: date.AddDate(0, 0, 3), RoomID: 1, Room: models.Room{ID: 1, RoomName: "General's Quarters"}},
+	}, nil
+}
+
+// ReservationsDeparting returns the reservations ending on a date
+func (this *testDBRepo) ReservationsDeparting(date time.Time) ([]models.Reservation, error) {
+
+	return []models.Reservation{
+		{ID: 2, FirstName: "Jane", LastName: "Doe", StartDate: date.AddDate(0, 0, -2), EndDate: date, RoomID: 2, CheckedInAt: date.AddDate(0, 0, -2), Room: models.Room{ID: 2, RoomName: "Major's Suite"}},
+	}, nil
+}
+
+// ReservationsInHouse returns the reservations that started before a date and end after it
+func (this *testDBRepo) ReservationsInHouse(date time.Time) ([]models.Reservation, error) {
+
+	return []models.Reservation{
+		{ID: 3, FirstName: "Ann", LastName: "Lee", StartDate: date.AddDate(0, 0, -1), EndDate: date.AddDate(0, 0, 1), RoomID: 3, CheckedInAt: date.AddDate(0, 0, -1), Room: models.Room{ID: 3, RoomName: "Major's Suite"}},
+	}, nil
+}
+
+// BlocksOn returns the owner and external blocks covering a date
+func (this *testDBRepo) BlocksOn(date time.Time) ([]models.RoomRestriction, error) {
+
+	return []models.RoomRestriction{
+		{ID: 7, StartDate: date, EndDate: date.AddDate(0, 0, 1), RoomID: 1002, RestrictionID: 2, Room: models.Room{ID: 1002, RoomName: "Fully Booked Room"}, Restriction: models.Restriction{ID: 2, RestrictionName: "Owner Block"}},
+	}, nil
+}
+
+// CheckInReservation records that the guest has arrived
+func (this *testDBRepo) CheckInReservation(id int) error {
+
+	if id == 1002 {
+		return errors.New("Some error!")
+	}
+
+	return nil
+}
+
+// CheckOutReservation records that the guest has left
+func (this *testDBRepo) CheckOutReservation(id int) error {
+
+	return nil
+}
+
+// GetRestrictionsByDate returns the restrictions of every room that overlap the nights from start up to end
+func (this *testDBRepo) GetRestrictionsByDate(start, end time.Time) ([]models.RoomRestriction, error) {
+
+	if start.Year() == 1000 {
+		return nil, errors.New("Some error!")
+	}
+
+	return []models.RoomRestriction{
+		{ID: 1, StartDate: start.AddDate(0, 0, 1), EndDate: start.AddDate(0, 0, 4), RoomID: 2, RestrictionID: 1, ReservationID: 1,
+			Reservation: models.Reservation{ID: 1, FirstName: "John", LastName: "Smith"}},
+		{ID: 2, StartDate: start.AddDate(0, 0, 2), EndDate: start.AddDate(0, 0, 3), RoomID: 3, RestrictionID: 2},
+		{ID: 3, StartDate: start.AddDate(0, 0, -3), EndDate: start.AddDate(0, 0, 2), RoomID: 1002, RestrictionID: 3, ICalFeedID: 1},
+	}, nil
+}
+
+// MoveReservation changes the room and dates of a reservation together with its room restriction
+func (this *testDBRepo) MoveReservation(id, roomID int, start, end time.Time) error {
+
+	if id == 1003 {
+		return errors.New("Some error!")
+	}
+
+	if roomID == 1002 {
+		return repository.ErrRoomUnavailable
+	}
+
+	return nil
+}
+
+// InsertAuditEntry appends an entry to the audit log
+func (this *testDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+
+	if e.EntityID == 1004 {
+		return errors.New("Some error!")
+	}
+
 	return nil
 }
+
+// AuditEntries returns a page of the audit log matching a query, newest first, and how many entries match in all
+func (this *testDBRepo) AuditEntries(q models.AuditQuery) ([]models.AuditEntry, int, error) {
+
+	if q.EntityID == 1000 {
+		return nil, 0, errors.New("Some error!")
+	}
+
+	all := []models.AuditEntry{
+		{ID: 2, UserID: 1, RemoteIP: "10.0.0.1", Action: "update", Entity: "reservation", EntityID: 1,
+			Changes: []models.AuditChange{{Field: "email", Before: "john@here.com", After: "john@there.com"}},
+			User:    models.User{ID: 1, FirstName: "Admin", LastName: "User"}},
+		{ID: 1, UserID: 1, RemoteIP: "10.0.0.1", Action: "create", Entity: "block", EntityID: 7,
+			Changes: []models.AuditChange{{Field: "room_id", After: "2"}},
+			User:    models.User{ID: 1, FirstName: "Admin", LastName: "User"}},
+		{ID: 3, APIKeyID: 4, RemoteIP: "10.0.0.2", Action: "delete", Entity: "reservation", EntityID: 9,
+			Changes: []models.AuditChange{{Field: "email", Before: "jane@here.com"}},
+			APIKey:  models.APIKey{ID: 4, Name: "Channel manager"}},
+	}
+
+	var entries []models.AuditEntry
+	for _, e := range all {
+		if (q.Entity != "" && e.Entity != q.Entity) || (q.EntityID > 0 && e.EntityID != q.EntityID) ||
+			(q.UserID > 0 && e.UserID != q.UserID) || (q.Action != "" && e.Action != q.Action) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, len(entries), nil
+}
+
+// DeletedReservations returns the reservations in the trash, most recently deleted first
+func (this *testDBRepo) DeletedReservations() ([]models.Reservation, error) {
+
+	deleted := time.Now().Add(-time.Hour)
+
+	return []models.Reservation{
+		{ID: 5, FirstName: "Jane", LastName: "Doe", RoomID: 2, StartDate: deleted.AddDate(0, 0, 7), EndDate: deleted.AddDate(0, 0, 9),
+			DeletedAt: deleted, Room: models.Room{ID: 2, RoomName: "General's Quarters"}},
+	}, nil
+}
+
+// GetDeletedReservationByID returns one reservation in the trash by ID
+func (this *testDBRepo) GetDeletedReservationByID(id int) (models.Reservation, error) {
+
+	var res models.Reservation
+
+	if id == 1000 {
+		return res, sql.ErrNoRows
+	}
+
+	if id == 1001 {
+		return res, errors.New("Some error!")
+	}
+
+	res.ID = id
+	res.DeletedAt = time.Now()
+
+	return res, nil
+}
+
+// RestoreReservation takes a reservation out of the trash and books its room again
+func (this *testDBRepo) RestoreReservation(id int) error {
+
+	if id == 1002 {
+		return repository.ErrRoomUnavailable
+	}
+
+	if id == 1003 {
+		return errors.New("Some error!")
+	}
+
+	return nil
+}
+
+// PurgeDeletedReservations permanently deletes the reservations put in the trash before a time, returning their IDs
+func (this *testDBRepo) PurgeDeletedReservations(before time.Time) ([]int, error) {
+
+	if before.Year() == 1000 {
+		return nil, errors.New("Some error!")
+	}
+
+	return []int{5, 6}, nil
+}
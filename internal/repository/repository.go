@@ -1,11 +1,15 @@
 package repository
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gummy789j/bookings/internal/models"
 )
 
+// ErrRoomUnavailable is returned when a change would double book a room
+var ErrRoomUnavailable = errors.New("room is not available for those dates")
+
 type DatabaseRepo interface {
 	AllUsers() bool
 	InsertReservation(res models.Reservation) (int, error)
@@ -15,9 +19,15 @@ type DatabaseRepo interface {
 	GetRoomByID(id int) (models.Room, error)
 	GetUserByID(id int) (models.User, error)
 	UpdateUser(user models.User) error
+	GetUserByEmail(email string) (models.User, error)
+	InsertUser(user models.User) (int, error)
+	UpdateUserPassword(id int, hash string) error
 	Authenticate(email, password string) (int, string, error)
 	AllReservations() ([]models.Reservation, error)
 	AllNewReservations() ([]models.Reservation, error)
+	SearchReservations(q models.ReservationQuery) ([]models.Reservation, int, error)
+	EachReservation(f models.ReservationFilter, fn func(models.Reservation) error) error
+	ImportReservations(reservations []models.Reservation, blocks []models.RoomRestriction) error
 	GetReservationByID(id int) (models.Reservation, error)
 	UpdateReservation(res models.Reservation) error
 	DeleteReservation(id int) error
@@ -25,5 +35,57 @@ type DatabaseRepo interface {
 	AllRooms() ([]models.Room, error)
 	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
 	InsertBlockForRoom(roomID int, startDate time.Time) error
+	GetBlockByID(id int) (models.RoomRestriction, error)
 	DeleteBlockByID(id int) error
+	GetRestrictionsForRoomSince(roomID int, since time.Time) ([]models.RoomRestriction, error)
+	UpdateRoomICalSecret(roomID int, secret string) error
+
+	InsertAPIKey(key models.APIKey) (int, error)
+	AllAPIKeys() ([]models.APIKey, error)
+	GetAPIKeyByID(id int) (models.APIKey, error)
+	GetAPIKeyByHash(hash string) (models.APIKey, error)
+	RevokeAPIKey(id int) error
+	TouchAPIKey(id int) error
+	InsertAPIKeyCall(call models.APIKeyCall) error
+	RecentAPIKeyCalls(keyID, limit int) ([]models.APIKeyCall, error)
+
+	InsertWebhook(hook models.Webhook) (int, error)
+	AllWebhooks() ([]models.Webhook, error)
+	ActiveWebhooks() ([]models.Webhook, error)
+	GetWebhookByID(id int) (models.Webhook, error)
+	DeleteWebhook(id int) error
+	InsertWebhookDelivery(d models.WebhookDelivery) error
+	WebhookDeliveries(webhookID, limit int) ([]models.WebhookDelivery, error)
+	GetWebhookDeliveryByID(id int) (models.WebhookDelivery, error)
+
+	InsertICalFeed(feed models.ICalFeed) (int, error)
+	AllICalFeeds() ([]models.ICalFeed, error)
+	GetICalFeedByID(id int) (models.ICalFeed, error)
+	DeleteICalFeed(id int) error
+	ICalFeedBlocks(feedID int) ([]models.RoomRestriction, error)
+	SaveICalFeedBlocks(feedID int, add, update []models.RoomRestriction, removeIDs []int) error
+	InsertICalImportRun(run models.ICalImportRun) error
+	ICalImportRuns(feedID, limit int) ([]models.ICalImportRun, error)
+	ICalConflicts(feedID int) ([]models.ICalConflict, error)
+
+	DashboardSummary(from, to time.Time) (models.DashboardSummary, error)
+	RoomOccupancy(from, to time.Time) ([]models.RoomOccupancy, error)
+
+	ReservationsArriving(date time.Time) ([]models.Reservation, error)
+	ReservationsDeparting(date time.Time) ([]models.Reservation, error)
+	ReservationsInHouse(date time.Time) ([]models.Reservation, error)
+	BlocksOn(date time.Time) ([]models.RoomRestriction, error)
+	CheckInReservation(id int) error
+	CheckOutReservation(id int) error
+
+	GetRestrictionsByDate(start, end time.Time) ([]models.RoomRestriction, error)
+	MoveReservation(id, roomID int, start, end time.Time) error
+
+	InsertAuditEntry(e models.AuditEntry) error
+	AuditEntries(q models.AuditQuery) ([]models.AuditEntry, int, error)
+
+	DeletedReservations() ([]models.Reservation, error)
+	GetDeletedReservationByID(id int) (models.Reservation, error)
+	RestoreReservation(id int) error
+	PurgeDeletedReservations(before time.Time) ([]int, error)
 }
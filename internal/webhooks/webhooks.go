@@ -0,0 +1,245 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository"
+)
+
+// Events that webhooks can subscribe to
+const (
+	ReservationCreated   = "reservation.created"
+	ReservationUpdated   = "reservation.updated"
+	ReservationCancelled = "reservation.cancelled"
+	ReservationRestored  = "reservation.restored"
+	ReservationProcessed = "reservation.processed"
+	BlockCreated         = "block.created"
+	BlockDeleted         = "block.deleted"
+)
+
+// Events lists every event in the order shown to admins
+var Events = []string{
+	ReservationCreated,
+	ReservationUpdated,
+	ReservationCancelled,
+	ReservationRestored,
+	ReservationProcessed,
+	BlockCreated,
+	BlockDeleted,
+}
+
+// SignatureHeader carries the HMAC-SHA256 of the body, keyed with the webhook secret
+const SignatureHeader = "X-Bookings-Signature"
+
+// EventHeader carries the event name
+const EventHeader = "X-Bookings-Event"
+
+// IsEvent reports whether name is a known event
+func IsEvent(name string) bool {
+	for _, e := range Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// payload is the JSON body sent to webhooks
+type payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewPayload builds the JSON body for an event
+func NewPayload(event string, data interface{}) ([]byte, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(payload{
+		ID:        id,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+}
+
+// NewSecret generates a random secret for signing payloads
+func NewSecret() (string, error) {
+	return randomHex(32)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for a body, "sha256=" followed by the hex HMAC
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is valid for body, receivers can use it to check deliveries
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Dispatcher sends events to the webhooks subscribed to them
+type Dispatcher struct {
+	DB     repository.DatabaseRepo
+	Client *http.Client
+	Logger *slog.Logger
+
+	// MaxAttempts is how many times a delivery is tried before giving up
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, it doubles after every failed attempt
+	Backoff time.Duration
+}
+
+// NewDispatcher returns a dispatcher with the default retry policy
+func NewDispatcher(db repository.DatabaseRepo, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Logger:      logger,
+		MaxAttempts: 5,
+		Backoff:     30 * time.Second,
+	}
+}
+
+// Dispatch delivers an event to every active webhook subscribed to it,
+// or only to ev.WebhookID when it is set, and waits for all deliveries to finish
+func (d *Dispatcher) Dispatch(ev models.WebhookEvent) {
+
+	var hooks []models.Webhook
+
+	if ev.WebhookID > 0 {
+		hook, err := d.DB.GetWebhookByID(ev.WebhookID)
+		if err != nil {
+			d.Logger.Error("cannot get webhook", slog.Int("webhook_id", ev.WebhookID), logging.Err(err))
+			return
+		}
+		hooks = append(hooks, hook)
+	} else {
+		active, err := d.DB.ActiveWebhooks()
+		if err != nil {
+			d.Logger.Error("cannot list webhooks", logging.Err(err))
+			return
+		}
+
+		for _, hook := range active {
+			if hook.Subscribes(ev.Event) {
+				hooks = append(hooks, hook)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook models.Webhook) {
+			defer wg.Done()
+
+			if err := d.Deliver(hook, ev.Event, ev.Payload); err != nil {
+				d.Logger.Error("giving up on webhook delivery", slog.Int("webhook_id", hook.ID), slog.String("event", ev.Event), logging.Err(err))
+			}
+		}(hook)
+	}
+
+	wg.Wait()
+}
+
+// Deliver posts a payload to one webhook, retrying with back-off until it succeeds or
+// MaxAttempts is reached. Every attempt is written to the delivery log.
+func (d *Dispatcher) Deliver(hook models.Webhook, event string, body []byte) error {
+
+	backoff := d.Backoff
+
+	var err error
+
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+
+		var status int
+		status, err = d.post(hook, event, body)
+
+		delivery := models.WebhookDelivery{
+			WebhookID:  hook.ID,
+			Event:      event,
+			Payload:    string(body),
+			Attempt:    attempt,
+			StatusCode: status,
+			Success:    err == nil,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		if logErr := d.DB.InsertWebhookDelivery(delivery); logErr != nil {
+			d.Logger.Error("cannot record webhook delivery", slog.Int("webhook_id", hook.ID), logging.Err(logErr))
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if attempt < d.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return err
+}
+
+// post makes one delivery attempt, any 2xx response counts as success
+func (d *Dispatcher) post(hook models.Webhook, event string, body []byte) (int, error) {
+
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookings-webhooks/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
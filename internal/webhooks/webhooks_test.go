@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gummy789j/bookings/internal/config"
+	"github.com/gummy789j/bookings/internal/logging"
+	"github.com/gummy789j/bookings/internal/models"
+	"github.com/gummy789j/bookings/internal/repository/dbrepo"
+)
+
+func testDispatcher() *Dispatcher {
+	var app config.AppConfig
+
+	d := NewDispatcher(dbrepo.NewTestRepo(&app), logging.Discard())
+	d.MaxAttempts = 3
+	d.Backoff = time.Millisecond
+
+	return d
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"reservation.created"}`)
+
+	sig := Sign("secret", body)
+
+	if !Verify("secret", body, sig) {
+		t.Error("signature did not verify with the same secret")
+	}
+
+	if Verify("other", body, sig) {
+		t.Error("signature verified with the wrong secret")
+	}
+
+	if Verify("secret", []byte(`{}`), sig) {
+		t.Error("signature verified for a different body")
+	}
+}
+
+func TestNewPayload(t *testing.T) {
+	body, err := NewPayload(ReservationCreated, map[string]int{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p struct {
+		ID    string         `json:"id"`
+		Event string         `json:"event"`
+		Data  map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.ID == "" || p.Event != ReservationCreated || p.Data["id"] != 7 {
+		t.Errorf("unexpected payload %s", body)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	hook := models.Webhook{ID: 1, Secret: "s3cret", Events: []string{ReservationCreated}, Active: true}
+
+	var got []byte
+	var gotSignature, gotEvent string
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ioutil.ReadAll(r.Body)
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotEvent = r.Header.Get(EventHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	hook.URL = receiver.URL
+
+	body, _ := NewPayload(ReservationCreated, nil)
+
+	err := testDispatcher().Deliver(hook, ReservationCreated, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(body) {
+		t.Errorf("receiver got %s, expected %s", got, body)
+	}
+
+	if !Verify(hook.Secret, got, gotSignature) {
+		t.Error("receiver could not verify the signature")
+	}
+
+	if gotEvent != ReservationCreated {
+		t.Errorf("expected event header %s but got %s", ReservationCreated, gotEvent)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	var calls int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	hook := models.Webhook{ID: 1, URL: receiver.URL, Secret: "s3cret"}
+
+	err := testDispatcher().Deliver(hook, BlockCreated, []byte(`{}`))
+	if err != nil {
+		t.Errorf("expected delivery to succeed on the third attempt, got %s", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts but got %d", calls)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	var calls int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	hook := models.Webhook{ID: 1, URL: receiver.URL, Secret: "s3cret"}
+
+	err := testDispatcher().Deliver(hook, BlockDeleted, []byte(`{}`))
+	if err == nil {
+		t.Error("expected delivery to fail")
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts but got %d", calls)
+	}
+}
+
+func TestWebhookSubscribes(t *testing.T) {
+	hook := models.Webhook{Events: []string{ReservationCreated, BlockCreated}}
+
+	if !hook.Subscribes(BlockCreated) {
+		t.Error("expected webhook to subscribe to block.created")
+	}
+
+	if hook.Subscribes(ReservationCancelled) {
+		t.Error("did not expect webhook to subscribe to reservation.cancelled")
+	}
+
+	if !IsEvent(ReservationProcessed) || IsEvent("reservation.deleted") {
+		t.Error("IsEvent does not match the known events")
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE restrictions (
+    id SERIAL PRIMARY KEY,
+    restriction_name varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
@@ -0,0 +1,3 @@
+ALTER TABLE room_restrictions DROP CONSTRAINT room_restrictions_reservations_id_fk;
+DROP INDEX reservations_email_idx;
+DROP INDEX reservations_last_name_idx;
@@ -0,0 +1,9 @@
+-- owner blocks, calendar blocks and imported bookings have no reservation, refuse rather than delete them
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM room_restrictions WHERE reservation_id IS NULL) THEN
+        RAISE EXCEPTION 'room_restrictions has rows without a reservation, such as owner blocks and imported bookings; remove them first to migrate down';
+    END IF;
+END
+$$;
+ALTER TABLE room_restrictions ALTER COLUMN reservation_id SET NOT NULL;
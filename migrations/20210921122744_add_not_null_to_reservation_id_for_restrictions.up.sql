@@ -0,0 +1,2 @@
+-- owner blocks have no reservation
+ALTER TABLE room_restrictions ALTER COLUMN reservation_id DROP NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE reservations ADD COLUMN processed integer NOT NULL DEFAULT 0;
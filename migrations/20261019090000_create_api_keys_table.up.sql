@@ -0,0 +1,17 @@
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    name varchar(255) NOT NULL DEFAULT '',
+    prefix varchar(11) NOT NULL,
+    key_hash varchar(64) NOT NULL,
+    scope varchar(10) NOT NULL DEFAULT 'read',
+    user_id integer,
+    last_used_at timestamp,
+    revoked_at timestamp,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX api_keys_key_hash_idx ON api_keys (key_hash);
+
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_users_id_fk
+    FOREIGN KEY (user_id) REFERENCES users (id) ON UPDATE CASCADE ON DELETE SET NULL;
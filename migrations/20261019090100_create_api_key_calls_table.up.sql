@@ -0,0 +1,15 @@
+CREATE TABLE api_key_calls (
+    id SERIAL PRIMARY KEY,
+    api_key_id integer NOT NULL,
+    method varchar(10) NOT NULL,
+    path varchar(255) NOT NULL,
+    status integer NOT NULL,
+    remote_ip varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE INDEX api_key_calls_api_key_id_idx ON api_key_calls (api_key_id);
+
+ALTER TABLE api_key_calls ADD CONSTRAINT api_key_calls_api_keys_id_fk
+    FOREIGN KEY (api_key_id) REFERENCES api_keys (id) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1,9 @@
+CREATE TABLE webhooks (
+    id SERIAL PRIMARY KEY,
+    url varchar(2048) NOT NULL,
+    secret varchar(64) NOT NULL,
+    events text NOT NULL DEFAULT '',
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
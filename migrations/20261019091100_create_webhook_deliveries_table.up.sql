@@ -0,0 +1,17 @@
+CREATE TABLE webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id integer NOT NULL,
+    event varchar(255) NOT NULL,
+    payload text NOT NULL,
+    attempt integer NOT NULL DEFAULT 1,
+    status_code integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    success boolean NOT NULL DEFAULT false,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id);
+
+ALTER TABLE webhook_deliveries ADD CONSTRAINT webhook_deliveries_webhooks_id_fk
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON UPDATE CASCADE ON DELETE CASCADE;
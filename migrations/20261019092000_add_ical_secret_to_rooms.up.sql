@@ -0,0 +1,3 @@
+ALTER TABLE rooms ADD COLUMN ical_secret varchar(64) NOT NULL DEFAULT '';
+
+UPDATE rooms SET ical_secret = md5(random()::text || id::text);
@@ -0,0 +1,4 @@
+INSERT INTO public.restrictions (id,restriction_name,created_at,updated_at) VALUES
+	 (3,'External booking','2026-10-19 00:00:00.000','2026-10-19 00:00:00.000');
+
+SELECT setval('restrictions_id_seq', (SELECT max(id) FROM public.restrictions));
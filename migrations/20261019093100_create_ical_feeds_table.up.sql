@@ -0,0 +1,11 @@
+CREATE TABLE ical_feeds (
+    id SERIAL PRIMARY KEY,
+    room_id integer NOT NULL,
+    name varchar(255) NOT NULL,
+    url varchar(2048) NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+ALTER TABLE ical_feeds ADD CONSTRAINT ical_feeds_rooms_id_fk
+    FOREIGN KEY (room_id) REFERENCES rooms (id) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+ALTER TABLE room_restrictions DROP CONSTRAINT room_restrictions_ical_feeds_id_fk;
+DROP INDEX room_restrictions_ical_feed_id_external_uid_idx;
+ALTER TABLE room_restrictions DROP COLUMN external_uid;
+ALTER TABLE room_restrictions DROP COLUMN ical_feed_id;
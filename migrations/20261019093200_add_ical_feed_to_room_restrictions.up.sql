@@ -0,0 +1,7 @@
+ALTER TABLE room_restrictions ADD COLUMN ical_feed_id integer;
+ALTER TABLE room_restrictions ADD COLUMN external_uid varchar(255);
+
+CREATE UNIQUE INDEX room_restrictions_ical_feed_id_external_uid_idx ON room_restrictions (ical_feed_id, external_uid);
+
+ALTER TABLE room_restrictions ADD CONSTRAINT room_restrictions_ical_feeds_id_fk
+    FOREIGN KEY (ical_feed_id) REFERENCES ical_feeds (id) ON UPDATE CASCADE ON DELETE CASCADE;
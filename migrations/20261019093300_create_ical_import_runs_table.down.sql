@@ -0,0 +1 @@
+DROP TABLE ical_import_runs;
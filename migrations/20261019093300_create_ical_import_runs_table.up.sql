@@ -0,0 +1,16 @@
+CREATE TABLE ical_import_runs (
+    id SERIAL PRIMARY KEY,
+    ical_feed_id integer NOT NULL,
+    added integer NOT NULL DEFAULT 0,
+    updated integer NOT NULL DEFAULT 0,
+    removed integer NOT NULL DEFAULT 0,
+    conflicts integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE INDEX ical_import_runs_ical_feed_id_idx ON ical_import_runs (ical_feed_id);
+
+ALTER TABLE ical_import_runs ADD CONSTRAINT ical_import_runs_ical_feeds_id_fk
+    FOREIGN KEY (ical_feed_id) REFERENCES ical_feeds (id) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1 @@
+DROP INDEX reservations_start_date_idx;
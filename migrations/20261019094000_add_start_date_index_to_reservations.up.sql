@@ -0,0 +1 @@
+CREATE INDEX reservations_start_date_idx ON reservations (start_date);
@@ -0,0 +1,2 @@
+ALTER TABLE reservations DROP COLUMN checked_out_at;
+ALTER TABLE reservations DROP COLUMN checked_in_at;
@@ -0,0 +1,2 @@
+ALTER TABLE reservations ADD COLUMN checked_in_at timestamp;
+ALTER TABLE reservations ADD COLUMN checked_out_at timestamp;
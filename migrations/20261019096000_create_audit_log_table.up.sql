@@ -0,0 +1,14 @@
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL DEFAULT 0,
+    remote_ip varchar(255) NOT NULL DEFAULT '',
+    action varchar(255) NOT NULL,
+    entity varchar(255) NOT NULL,
+    entity_id integer NOT NULL DEFAULT 0,
+    changes text NOT NULL DEFAULT '[]',
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE INDEX audit_log_entity_entity_id_idx ON audit_log (entity, entity_id);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
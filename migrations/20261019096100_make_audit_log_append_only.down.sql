@@ -0,0 +1,2 @@
+drop trigger if exists audit_log_append_only on audit_log;
+drop function if exists audit_log_append_only();
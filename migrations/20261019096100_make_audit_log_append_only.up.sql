@@ -0,0 +1,8 @@
+create function audit_log_append_only() returns trigger as $$
+begin
+    raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_log_append_only before update or delete on audit_log
+    for each row execute procedure audit_log_append_only();
@@ -0,0 +1,2 @@
+DROP INDEX reservations_deleted_at_idx;
+ALTER TABLE reservations DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE reservations ADD COLUMN deleted_at timestamp;
+
+CREATE INDEX reservations_deleted_at_idx ON reservations (deleted_at);
@@ -0,0 +1 @@
+ALTER TABLE api_key_calls ALTER COLUMN path TYPE varchar(255) USING left(path, 255);
@@ -0,0 +1 @@
+ALTER TABLE audit_log ADD COLUMN api_key_id integer NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+// Package migrations holds the database schema changes, embedded in the binary so it can migrate on its own.
+// Each change is a pair of files, <version>_<name>.up.sql and <version>_<name>.down.sql, applied in version order.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// FS is the directory of migration files
+var FS fs.FS = files
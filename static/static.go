@@ -0,0 +1,13 @@
+// Package static holds the stylesheets, scripts and images served under /static, embedded in the binary.
+package static
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed admin css images js
+var files embed.FS
+
+// FS is the directory of static files
+var FS fs.FS = files
@@ -0,0 +1,13 @@
+// Package templates holds the page and layout templates, embedded in the binary so it runs from any directory.
+package templates
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.tmpl
+var files embed.FS
+
+// FS is the directory of *.page.tmpl and *.layout.tmpl files
+var FS fs.FS = files